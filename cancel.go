@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"path"
+)
+
+// CancelWorkflow cancels all unfinished jobs in a workflow log directory.
+// Cancelled jobs are recorded with CancelledExitCode. Return the number of
+// cancelled jobs.
+func CancelWorkflow(workflowLogRoot string) (int, error) {
+	var metadata WorkflowMetaData
+	err := LoadJsonFromFile(path.Join(workflowLogRoot, "runtime.json"), &metadata)
+	if err != nil {
+		return 0, fmt.Errorf("Cannot load runtime information: %s", err.Error())
+	}
+
+	cancelled := 0
+	for _, v := range metadata.Tasks {
+		jobRoot := path.Join(workflowLogRoot, fmt.Sprintf("job%03d", v.ID))
+		if _, err := readReturnCode(jobRoot); err == nil {
+			continue
+		}
+
+		_, err := CancelJob(jobRoot)
+		if err != nil {
+			return cancelled, fmt.Errorf("Cannot cancel job %d: %s", v.ID, err.Error())
+		}
+
+		// a job may be finished while cancelling
+		written, err := writeReturnCodeIfNotExist(jobRoot, CancelledExitCode)
+		if err != nil {
+			return cancelled, err
+		}
+		if written {
+			err = writeJobResultReason(jobRoot, "Cancelled by user")
+			if err != nil {
+				return cancelled, err
+			}
+			fmt.Printf("Cancelled: %s\n", v.ShellScript)
+			cancelled++
+		}
+	}
+
+	return cancelled, nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"os"
+	"path"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestCancelWorkflow(t *testing.T) {
+	ClearCache()
+	tmp, err := NewTempDir("cancel")
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	os.Args[0] = path.Join(tmp.originalCwd, "shellflow")
+	defer tmp.Close()
+
+	testScript := `sleep 30; echo 1 > [[a]]
+cat ((a)) > [[b]]
+`
+	env := NewEnvironment()
+	builder, err := ParseShellflow(strings.NewReader(testScript), env, make(map[string]interface{}))
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	gen, err := GenerateTaskScripts("cancel.sf", "", env, builder)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	result := make(chan error)
+	go func() {
+		result <- ExecuteLocalSingle(gen)
+	}()
+
+	for i := 0; i < 100; i++ {
+		if _, err := readLocalRunPid(gen.scripts[1].JobRoot); err == nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	cancelled, err := CancelWorkflow(gen.workflowRoot)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	if cancelled < 1 {
+		t.Fatalf("bad number of cancelled jobs: %d", cancelled)
+	}
+
+	select {
+	case err = <-result:
+		if err == nil || !IsExecutionError(err) {
+			t.Fatalf("execution error should be returned: %s", err)
+		}
+	case <-time.After(20 * time.Second):
+		t.Fatalf("job is not cancelled")
+	}
+
+	log, err := CollectLogsForOneWork(gen.workflowRoot)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	if log.State() != WorkflowCancelled {
+		t.Fatalf("bad workflow state: %s", log.State())
+	}
+	if log.JobLogs[0].State() != JobCancelled || log.JobLogs[0].Reason != "Cancelled by user" {
+		t.Fatalf("bad job state: %s %s", log.JobLogs[0].State(), log.JobLogs[0].Reason)
+	}
+	if s := log.JobLogs[1].State(); s != JobCancelled && s != JobFailed {
+		t.Fatalf("bad job state: %s", s)
+	}
+
+	if cancelled, err = CancelWorkflow(gen.workflowRoot); err != nil || cancelled != 0 {
+		t.Fatalf("finished jobs should not be cancelled: %d %s", cancelled, err)
+	}
+}
+
+func TestInterruptLocalJobs(t *testing.T) {
+	ClearCache()
+	tmp, err := NewTempDir("interrupt")
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	os.Args[0] = path.Join(tmp.originalCwd, "shellflow")
+	defer tmp.Close()
+
+	testScript := `sleep 30; echo 1 > [[a]]
+sleep 30; echo 2 > [[b]]
+cat ((a)) ((b)) > [[c]]
+`
+	env := NewEnvironment()
+	builder, err := ParseShellflow(strings.NewReader(testScript), env, make(map[string]interface{}))
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	gen, err := GenerateTaskScripts("interrupt.sf", "", env, builder)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	result := make(chan error)
+	go func() {
+		result <- executeLocalParallelWithBudget(gen, 2, &LocalResourceBudget{CPU: 2})
+	}()
+
+	for i := 0; i < 100; i++ {
+		localJobs.Lock()
+		running := len(localJobs.interrupt)
+		localJobs.Unlock()
+		if running == 2 {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	err = syscall.Kill(os.Getpid(), syscall.SIGINT)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	select {
+	case err = <-result:
+		if err == nil || !IsExecutionError(err) {
+			t.Fatalf("execution error should be returned: %s", err)
+		}
+	case <-time.After(20 * time.Second):
+		t.Fatalf("jobs are not interrupted")
+	}
+
+	log, err := CollectLogsForOneWork(gen.workflowRoot)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	for _, v := range log.JobLogs[:2] {
+		if v.State() != JobCancelled || v.Reason != "Interrupted by signal" {
+			t.Fatalf("bad job state: %s %s", v.State(), v.Reason)
+		}
+		pid, err := readLocalRunPid(v.JobLogRoot)
+		if err != nil {
+			t.Fatalf("error: %s", err.Error())
+		}
+		if isLocalProcessAlive(pid) {
+			t.Fatalf("job is still running: %d", pid)
+		}
+	}
+	if log.JobLogs[2].ExitCode == 0 {
+		t.Fatalf("dependent job should not be run")
+	}
+}
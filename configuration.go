@@ -7,6 +7,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
 )
@@ -84,14 +85,173 @@ func (m Memory) String() string {
 }
 
 type CommandConfiguration struct {
-	RegExp          string
-	SGEOption       []string
-	DontInheirtPath bool
-	RunImmediate    bool
+	RegExp            string
+	SGEOption         []string          `toml:",omitempty"`
+	SlurmOption       []string          `toml:",omitempty"`
+	PBSOption         []string          `toml:",omitempty"`
+	LSFOption         []string          `toml:",omitempty"`
+	CondorOption      []string          `toml:",omitempty"`
+	DontInheirtPath   bool              `toml:",omitempty"`
+	RunImmediate      bool              `toml:",omitempty"`
+	Retry             int               `toml:",omitzero"`
+	RetryOnExitCodes  []int             `toml:",omitempty"`
+	Timeout           string            `toml:",omitempty"`
+	Memory            string            `toml:",omitempty"`
+	CPU               int               `toml:",omitzero"`
+	Walltime          string            `toml:",omitempty"`
+	GPU               int               `toml:",omitzero"`
+	Container         string            `toml:",omitempty"`
+	ContainerEngine   string            `toml:",omitempty"`
+	ContainerOption   []string          `toml:",omitempty"`
+	Singularity       string            `toml:",omitempty"`
+	SingularityOption []string          `toml:",omitempty"`
+	Conda             string            `toml:",omitempty"`
+	Modules           []string          `toml:",omitempty"`
+	Environment       map[string]string `toml:",omitempty"`
+
+	// Source is a configuration file which this rule is loaded from
+	Source string `toml:"-"`
 }
 
 func (v *CommandConfiguration) String() string {
-	return fmt.Sprintf("SGEOption: %s / DontInheirtPath: %t / RunImmediate: %t", v.SGEOption, v.DontInheirtPath, v.RunImmediate)
+	return fmt.Sprintf("SGEOption: %s / SlurmOption: %s / PBSOption: %s / LSFOption: %s / CondorOption: %s / DontInheirtPath: %t / RunImmediate: %t / Retry: %d / RetryOnExitCodes: %d / Timeout: %s / Memory: %s / CPU: %d / Walltime: %s / GPU: %d / Container: %s / ContainerEngine: %s / ContainerOption: %s / Singularity: %s / SingularityOption: %s / Conda: %s / Modules: %s / Environment: %v", v.SGEOption, v.SlurmOption, v.PBSOption, v.LSFOption, v.CondorOption, v.DontInheirtPath, v.RunImmediate, v.Retry, v.RetryOnExitCodes, v.Timeout, v.Memory, v.CPU, v.Walltime, v.GPU, v.Container, v.ContainerEngine, v.ContainerOption, v.Singularity, v.SingularityOption, v.Conda, v.Modules, v.Environment)
+}
+
+// TimeoutDuration returns parsed timeout. Zero is returned if timeout is not set.
+func (v *CommandConfiguration) TimeoutDuration() (time.Duration, error) {
+	if v.Timeout == "" {
+		return 0, nil
+	}
+	timeout, err := time.ParseDuration(v.Timeout)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid timeout: %s", err.Error())
+	}
+	if timeout < 0 {
+		return 0, fmt.Errorf("Invalid timeout: %s", v.Timeout)
+	}
+	return timeout, nil
+}
+
+// MemoryRequest returns parsed memory request. Zero is returned if memory is not set.
+func (v *CommandConfiguration) MemoryRequest() (Memory, error) {
+	if strings.TrimSpace(v.Memory) == "" {
+		return Memory{}, nil
+	}
+	memory, err := NewMemory(strings.TrimSpace(v.Memory))
+	if err != nil {
+		return Memory{}, fmt.Errorf("Invalid memory: %s", v.Memory)
+	}
+	if memory.Byte() < 0 {
+		return Memory{}, fmt.Errorf("Invalid memory: %s", v.Memory)
+	}
+	return memory, nil
+}
+
+// WalltimeDuration returns parsed walltime. Zero is returned if walltime is not set.
+func (v *CommandConfiguration) WalltimeDuration() (time.Duration, error) {
+	if v.Walltime == "" {
+		return 0, nil
+	}
+	walltime, err := time.ParseDuration(v.Walltime)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid walltime: %s", err.Error())
+	}
+	if walltime < 0 {
+		return 0, fmt.Errorf("Invalid walltime: %s", v.Walltime)
+	}
+	return walltime, nil
+}
+
+// Validate checks values of resource requests and timeout
+func (v *CommandConfiguration) Validate() error {
+	if _, err := v.TimeoutDuration(); err != nil {
+		return err
+	}
+	if _, err := v.MemoryRequest(); err != nil {
+		return err
+	}
+	if _, err := v.WalltimeDuration(); err != nil {
+		return err
+	}
+	if v.CPU < 0 {
+		return fmt.Errorf("Invalid CPU: %d", v.CPU)
+	}
+	if v.GPU < 0 {
+		return fmt.Errorf("Invalid GPU: %d", v.GPU)
+	}
+	if v.ContainerEngine != "" && !containerEngines[v.ContainerEngine] {
+		return fmt.Errorf("Unknown container engine: %s", v.ContainerEngine)
+	}
+	if v.Container != "" && v.Singularity != "" {
+		return fmt.Errorf("Container and Singularity cannot be used at once")
+	}
+	return nil
+}
+
+// ApplyAnnotation overrides options with an annotation written in a workflow
+// like "#@ memory=8G cpu=4 retry=2 immediate"
+func (v *CommandConfiguration) ApplyAnnotation(annotation map[string]string) error {
+	parseInt := func(key string, value string) (int, error) {
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return 0, fmt.Errorf("Invalid %s: %s", key, value)
+		}
+		return i, nil
+	}
+	parseBool := func(key string, value string) (bool, error) {
+		if value == "" {
+			return true, nil
+		}
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return false, fmt.Errorf("Invalid %s: %s", key, value)
+		}
+		return b, nil
+	}
+
+	var err error
+	for key, value := range annotation {
+		switch key {
+		case "memory":
+			v.Memory = value
+		case "cpu":
+			v.CPU, err = parseInt(key, value)
+		case "gpu":
+			v.GPU, err = parseInt(key, value)
+		case "walltime":
+			v.Walltime = value
+		case "timeout":
+			v.Timeout = value
+		case "retry":
+			v.Retry, err = parseInt(key, value)
+		case "immediate":
+			v.RunImmediate, err = parseBool(key, value)
+		case "container":
+			v.Container = value
+		case "container_engine":
+			v.ContainerEngine = value
+		case "singularity":
+			v.Singularity = value
+		case "conda":
+			v.Conda = value
+		case "modules":
+			v.Modules = strings.Split(value, ",")
+		case "dont_inherit_path":
+			v.DontInheirtPath, err = parseBool(key, value)
+		default:
+			return fmt.Errorf("Unknown annotation: %s", key)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Local is resources which can be used by local executer at once
+type Local struct {
+	CPU    int
+	Memory string
 }
 
 type Backend struct {
@@ -99,64 +259,206 @@ type Backend struct {
 }
 
 type Configuration struct {
-	Environment map[string]string
-	Backend     Backend
-	Command     []CommandConfiguration
+	Timeout          string            `toml:",omitempty"`
+	Environment      map[string]string `toml:",omitempty"`
+	Backend          Backend
+	Local            Local
+	ResourceTemplate map[string]ResourceTemplate `toml:",omitempty"`
+	Command          []CommandConfiguration      `toml:",omitempty"`
 }
 
 //go:generate go-assets-builder --package=main --output=assets.go default_config.toml
 
+// SystemConfig is a system-wide configuration file
+var SystemConfig = "/etc/shellflow.toml"
+
+// ShellflowConfig is a configuration file of a user
 var ShellflowConfig = os.ExpandEnv("${HOME}/.shellflow.toml")
 
+// ProjectConfig is a configuration file in a current directory
+const ProjectConfig = "shellflow.toml"
+
+// ExtraConfig is a configuration file given with -config option
+var ExtraConfig = ""
+
+const builtinConfigSource = "built-in"
+const environmentConfigSource = "environment variables"
+
+// ConfigurationLayer is a configuration loaded from one source
+type ConfigurationLayer struct {
+	Source string
+	Config *Configuration
+}
+
+// LoadConfiguration loads and merges configurations from all sources.
+// See LoadConfigurationLayers for precedence of sources.
 func LoadConfiguration() (*Configuration, error) {
-	// prefer local conf file
-	localConfFile, err := os.Open("shellflow.toml")
-	if err == nil {
+	layers, err := LoadConfigurationLayers()
+	if err != nil {
+		return nil, err
+	}
+	return MergeConfigurations(layers), nil
+}
+
+// LoadConfigurationLayers loads configurations in order of priority from low
+// to high: built-in defaults, system-wide file, user file, project file,
+// a file given with -config option and SHELLFLOW_* environment variables.
+// Missing files are skipped except a file given with -config option.
+func LoadConfigurationLayers() ([]ConfigurationLayer, error) {
+	layers := make([]ConfigurationLayer, 0)
+
+	defaultConf, err := Assets.Open("/default_config.toml")
+	if err != nil {
+		return nil, fmt.Errorf("Cannot open default config.: %s", err.Error())
+	}
+	defer defaultConf.Close()
+	var builtin Configuration
+	_, err = toml.DecodeReader(defaultConf, &builtin)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read default TOML. %s", err.Error())
+	}
+	layers = append(layers, ConfigurationLayer{builtinConfigSource, &builtin})
+
+	for _, v := range []string{SystemConfig, ShellflowConfig, ProjectConfig, ExtraConfig} {
+		if v == "" {
+			continue
+		}
 		var conf Configuration
-		_, err = toml.DecodeReader(localConfFile, &conf)
-		if err != nil {
-			return nil, fmt.Errorf("cannot read local TOML. %s", err.Error())
+		_, err := toml.DecodeFile(v, &conf)
+		if os.IsNotExist(err) && v != ExtraConfig {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("cannot read TOML %s. %s", v, err.Error())
 		}
-		return &conf, nil
+		layers = append(layers, ConfigurationLayer{v, &conf})
 	}
 
-	confFile, err := os.Open(ShellflowConfig)
-	if err == nil {
-		defer confFile.Close()
-	} else if os.IsNotExist(err) {
-		// copy default config file from asset
-		defaultConf, err := Assets.Open("/default_config.toml")
+	envConf, err := configurationFromEnvironment()
+	if err != nil {
+		return nil, err
+	}
+	if envConf != nil {
+		layers = append(layers, ConfigurationLayer{environmentConfigSource, envConf})
+	}
+
+	return layers, nil
+}
+
+// configurationFromEnvironment creates configuration from SHELLFLOW_BACKEND,
+// SHELLFLOW_TIMEOUT, SHELLFLOW_LOCAL_CPU and SHELLFLOW_LOCAL_MEMORY.
+// nil is returned if none of them are set.
+func configurationFromEnvironment() (*Configuration, error) {
+	var conf Configuration
+	found := false
+	if v := os.Getenv("SHELLFLOW_BACKEND"); v != "" {
+		conf.Backend.Type = v
+		found = true
+	}
+	if v := os.Getenv("SHELLFLOW_TIMEOUT"); v != "" {
+		conf.Timeout = v
+		found = true
+	}
+	if v := os.Getenv("SHELLFLOW_LOCAL_CPU"); v != "" {
+		cpu, err := strconv.Atoi(v)
 		if err != nil {
-			return nil, fmt.Errorf("Cannot open default config.: %s", err.Error())
+			return nil, fmt.Errorf("Invalid SHELLFLOW_LOCAL_CPU: %s", v)
 		}
-		defer defaultConf.Close()
-		confFileToWrite, err := os.OpenFile(ShellflowConfig, os.O_CREATE|os.O_WRONLY, 0640)
-		if err != nil {
-			return nil, err
+		conf.Local.CPU = cpu
+		found = true
+	}
+	if v := os.Getenv("SHELLFLOW_LOCAL_MEMORY"); v != "" {
+		conf.Local.Memory = v
+		found = true
+	}
+	if !found {
+		return nil, nil
+	}
+	return &conf, nil
+}
+
+// MergeConfigurations merges configurations ordered from low to high
+// priority. Values in a higher priority layer override lower ones, and
+// command rules of a higher priority layer are checked earlier.
+func MergeConfigurations(layers []ConfigurationLayer) *Configuration {
+	merged := &Configuration{}
+	for _, layer := range layers {
+		v := layer.Config
+		if v.Timeout != "" {
+			merged.Timeout = v.Timeout
 		}
-		defer confFileToWrite.Close()
-		_, err = io.Copy(confFileToWrite, defaultConf)
-		if err != nil {
-			return nil, fmt.Errorf("Cannot write default config data. %s", err.Error())
+		for key, value := range v.Environment {
+			if merged.Environment == nil {
+				merged.Environment = make(map[string]string)
+			}
+			merged.Environment[key] = value
 		}
-		err = confFileToWrite.Sync()
-		if err != nil {
-			return nil, fmt.Errorf("Cannot sync config data. %s", err.Error())
+		if v.Backend.Type != "" {
+			merged.Backend.Type = v.Backend.Type
 		}
+		if v.Local.CPU != 0 {
+			merged.Local.CPU = v.Local.CPU
+		}
+		if v.Local.Memory != "" {
+			merged.Local.Memory = v.Local.Memory
+		}
+		for backend, template := range v.ResourceTemplate {
+			if merged.ResourceTemplate == nil {
+				merged.ResourceTemplate = make(map[string]ResourceTemplate)
+			}
+			current := merged.ResourceTemplate[backend]
+			if template.Memory != nil {
+				current.Memory = template.Memory
+			}
+			if template.CPU != nil {
+				current.CPU = template.CPU
+			}
+			if template.Walltime != nil {
+				current.Walltime = template.Walltime
+			}
+			if template.GPU != nil {
+				current.GPU = template.GPU
+			}
+			merged.ResourceTemplate[backend] = current
+		}
+	}
 
-		confFile, err = os.Open(ShellflowConfig)
-		if err != nil {
-			return nil, fmt.Errorf("Cannot open config file for read. %s", err.Error())
+	for i := len(layers) - 1; i >= 0; i-- {
+		for _, v := range layers[i].Config.Command {
+			v.Source = layers[i].Source
+			merged.Command = append(merged.Command, v)
 		}
-		defer confFile.Close()
-	} else if err != nil {
-		return nil, err
 	}
+	return merged
+}
 
-	var conf Configuration
-	_, err = toml.DecodeReader(confFile, &conf)
+// ShowConfiguration prints effective configuration as TOML with sources of
+// command rules.
+func ShowConfiguration(w io.Writer) error {
+	layers, err := LoadConfigurationLayers()
 	if err != nil {
-		return nil, fmt.Errorf("cannot read TOML. %s", err.Error())
+		return err
 	}
-	return &conf, nil
+	conf := MergeConfigurations(layers)
+
+	fmt.Fprintf(w, "# Configuration sources (from low to high priority)\n")
+	for _, v := range layers {
+		fmt.Fprintf(w, "#   %s\n", v.Source)
+	}
+	fmt.Fprintf(w, "\n")
+
+	global := *conf
+	global.Command = nil
+	err = toml.NewEncoder(w).Encode(global)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range conf.Command {
+		fmt.Fprintf(w, "\n# from: %s\n", v.Source)
+		err = toml.NewEncoder(w).Encode(struct{ Command []CommandConfiguration }{[]CommandConfiguration{v}})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
@@ -1,8 +1,12 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
+	"io/ioutil"
+	"os"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/BurntSushi/toml"
@@ -167,3 +171,113 @@ func TestTomlLoad2(t *testing.T) {
 		t.Fatalf("error: %s", err.Error())
 	}
 }
+
+func TestLoadConfigurationLayers(t *testing.T) {
+	tmp, err := NewTempDir("config_layers")
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	defer tmp.Close()
+
+	originalSystem, originalUser, originalExtra := SystemConfig, ShellflowConfig, ExtraConfig
+	defer func() {
+		SystemConfig, ShellflowConfig, ExtraConfig = originalSystem, originalUser, originalExtra
+	}()
+	SystemConfig = Abs("system.toml")
+	ShellflowConfig = Abs("user.toml")
+	ExtraConfig = Abs("extra.toml")
+
+	files := map[string]string{
+		"system.toml": `Timeout = "1h"
+[Environment]
+A = "system"
+B = "system"
+[Backend]
+Type = "sge"
+[[Command]]
+RegExp = "samtools"
+CPU = 1
+`,
+		"user.toml": `[Environment]
+B = "user"
+[Local]
+CPU = 4
+[ResourceTemplate.sge]
+CPU = ["-pe", "smp", "{{cpu}}"]
+`,
+		ProjectConfig: `Timeout = "2h"
+[[Command]]
+RegExp = "samtools"
+CPU = 2
+`,
+		"extra.toml": `[[Command]]
+RegExp = "gatk"
+Memory = "8G"
+`,
+	}
+	for k, v := range files {
+		err = ioutil.WriteFile(k, []byte(v), 0644)
+		if err != nil {
+			t.Fatalf("error: %s", err.Error())
+		}
+	}
+
+	os.Setenv("SHELLFLOW_BACKEND", "slurm")
+	defer os.Unsetenv("SHELLFLOW_BACKEND")
+
+	layers, err := LoadConfigurationLayers()
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	sources := make([]string, 0)
+	for _, v := range layers {
+		sources = append(sources, v.Source)
+	}
+	if expected := []string{builtinConfigSource, SystemConfig, ShellflowConfig, ProjectConfig, ExtraConfig, environmentConfigSource}; !reflect.DeepEqual(sources, expected) {
+		t.Fatalf("bad sources: %s", sources)
+	}
+
+	conf := MergeConfigurations(layers)
+	if conf.Timeout != "2h" || conf.Backend.Type != "slurm" || conf.Local.CPU != 4 {
+		t.Fatalf("bad configuration: %v", conf)
+	}
+	if !reflect.DeepEqual(conf.Environment, map[string]string{"A": "system", "B": "user"}) {
+		t.Fatalf("bad environment: %v", conf.Environment)
+	}
+	if v := conf.ResourceTemplate["sge"]; !reflect.DeepEqual(v.CPU, []string{"-pe", "smp", "{{cpu}}"}) || v.Memory != nil {
+		t.Fatalf("bad resource template: %v", v)
+	}
+
+	// rules in higher priority files are checked earlier
+	expectedRules := []struct {
+		regexp string
+		source string
+	}{
+		{"gatk", ExtraConfig},
+		{"samtools", ProjectConfig},
+		{"samtools", SystemConfig},
+	}
+	for i, v := range expectedRules {
+		if conf.Command[i].RegExp != v.regexp || conf.Command[i].Source != v.source {
+			t.Fatalf("bad command rule %d: %s %s", i, conf.Command[i].RegExp, conf.Command[i].Source)
+		}
+	}
+	if conf.Command[len(conf.Command)-1].Source != builtinConfigSource {
+		t.Fatalf("bad command rule: %s", conf.Command[len(conf.Command)-1].Source)
+	}
+
+	var buf bytes.Buffer
+	err = ShowConfiguration(&buf)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	if !strings.Contains(buf.String(), "\n# from: "+ProjectConfig+"\n[[Command]]\n  RegExp = \"samtools\"\n  CPU = 2\n") {
+		t.Fatalf("bad configuration output: %s", buf.String())
+	}
+
+	// file given with -config option should exist
+	ExtraConfig = Abs("not-found.toml")
+	if _, err := LoadConfigurationLayers(); err == nil {
+		t.Fatalf("missing configuration file should be error")
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const containerImageFileName = "container-image.txt"
+
+// DefaultContainerEngine is used when ContainerEngine is not configured
+const DefaultContainerEngine = "docker"
+
+var containerEngines = map[string]bool{
+	"docker": true,
+	"podman": true,
+}
+
+// ContainerEngineName returns a command name of container engine. Empty
+// string is returned if a command does not run in a container.
+func (v *CommandConfiguration) ContainerEngineName() string {
+	if v.Container == "" {
+		return ""
+	}
+	if v.ContainerEngine == "" {
+		return DefaultContainerEngine
+	}
+	return v.ContainerEngine
+}
+
+// scriptCommand returns a command line to run script.sh. If a container is
+// configured, script.sh is run in the container with work directory and job
+// log directory bind-mounted, and uid/gid of the user are preserved.
+// Environment variables exported in run.sh are passed to the container.
+// Singularity image is run with singularity exec in the same way.
+func scriptCommand(conf *CommandConfiguration, workDir string, jobDir string, environment map[string]string) string {
+	bash := fmt.Sprintf("/bin/bash -o pipefail -e \"%s/script.sh\"", jobDir)
+	if conf.Singularity != "" {
+		args := []string{"singularity", "exec"}
+		for _, v := range []string{workDir, jobDir} {
+			args = append(args, "--bind", strconv.Quote(v))
+		}
+		args = append(args, "--pwd", strconv.Quote(workDir))
+		args = append(args, conf.SingularityOption...)
+		args = append(args, strconv.Quote(conf.Singularity), bash)
+		return strings.Join(args, " ")
+	}
+
+	engine := conf.ContainerEngineName()
+	if engine == "" {
+		return bash
+	}
+
+	args := []string{engine, "run", "--rm", "-u", "\"$(id -u):$(id -g)\""}
+	for _, v := range []string{workDir, jobDir} {
+		args = append(args, "-v", strconv.Quote(v+":"+v))
+	}
+	args = append(args, "-w", strconv.Quote(workDir))
+	names := make([]string, 0, len(environment))
+	for k := range environment {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, v := range names {
+		args = append(args, "-e", v)
+	}
+	args = append(args, conf.ContainerOption...)
+	args = append(args, strconv.Quote(conf.Container), bash)
+	return strings.Join(args, " ")
+}
+
+// writeContainerImageScript writes a part of run.sh which pulls a container
+// image if it is not available, and records ID of the image in a job log
+// directory.
+func writeContainerImageScript(w io.Writer, conf *CommandConfiguration, jobDir string) {
+	engine := conf.ContainerEngineName()
+	image := strconv.Quote(conf.Container)
+	fmt.Fprintf(w, `if ! %s image inspect %s > /dev/null 2>&1; then
+    %s pull %s > /dev/null || exit 1
+fi
+%s image inspect --format "{{.Id}}" %s > "%s/%s" || exit 1
+`, engine, image, engine, image, engine, image, jobDir, containerImageFileName)
+}
+
+// containerImageID returns ID of a container image in local machine.
+func containerImageID(engine string, image string) (string, error) {
+	out, err := exec.Command(engine, "image", "inspect", "--format", "{{.Id}}", image).Output()
+	if err != nil {
+		return "", fmt.Errorf("Cannot inspect container image %s: %s", image, err.Error())
+	}
+	return strings.TrimSpace(string(out)), nil
+}
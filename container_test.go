@@ -0,0 +1,272 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+const fakeDocker = `#!/bin/bash
+FAKE_DIR="$(dirname "$0")"
+case "$1" in
+    image)
+        cat "$FAKE_DIR/image-id" 2>/dev/null || echo sha256:0123
+        ;;
+    pull)
+        ;;
+    run)
+        echo "$@" >> "$FAKE_DIR/docker.log"
+        # environment variables of host are not visible in a container
+        ENVS=("PATH=$PATH")
+        while [ "$1" != "/bin/bash" ]; do
+            if [ "$1" = "-e" ]; then
+                ENVS+=("$2=${!2}")
+                shift
+            fi
+            shift
+        done
+        exec env -i "${ENVS[@]}" "$@"
+        ;;
+esac
+`
+
+func TestExecuteInContainer(t *testing.T) {
+	ClearCache()
+	fakeDir, cleanup := setupFakeCommands(t, map[string]string{"docker": fakeDocker})
+	defer cleanup()
+
+	tmp, err := NewTempDir("container")
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	os.Args[0] = path.Join(tmp.originalCwd, "shellflow")
+	defer tmp.Close()
+
+	testScript := `echo hello > [[a]] #@ container=biocontainers/samtools:1.9
+`
+	run := func() *TaskScripts {
+		ClearCache()
+		env := NewEnvironment()
+		builder, err := ParseShellflow(strings.NewReader(testScript), env, make(map[string]interface{}))
+		if err != nil {
+			t.Fatalf("error: %s", err.Error())
+		}
+
+		gen, err := GenerateTaskScripts("container.sf", "", env, builder)
+		if err != nil {
+			t.Fatalf("error: %s", err.Error())
+		}
+
+		err = ExecuteLocalSingle(gen)
+		if err != nil {
+			t.Fatalf("error: %s", err.Error())
+		}
+		return gen
+	}
+
+	gen := run()
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	dockerLog, err := ioutil.ReadFile(path.Join(fakeDir, "docker.log"))
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	if !strings.HasPrefix(string(dockerLog), "run --rm -u ") ||
+		!strings.Contains(string(dockerLog), "-v "+cwd+":"+cwd+" ") ||
+		!strings.Contains(string(dockerLog), "-w "+cwd+" biocontainers/samtools:1.9 /bin/bash") {
+		t.Fatalf("bad docker argument: %s", dockerLog)
+	}
+
+	log, err := CollectLogsForOneWork(gen.workflowRoot)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	if log.JobLogs[0].State() != JobDone || log.JobLogs[0].ContainerImage != "sha256:0123" {
+		t.Fatalf("bad job log: %s", log.JobLogs[0])
+	}
+
+	// same image can be reused
+	gen = run()
+	if !gen.builder.Tasks[0].ShouldSkip {
+		t.Fatalf("job should be reused")
+	}
+
+	// image is updated
+	err = ioutil.WriteFile(path.Join(fakeDir, "image-id"), []byte("sha256:4567\n"), 0644)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	gen = run()
+	if gen.builder.Tasks[0].ShouldSkip {
+		t.Fatalf("job should not be reused")
+	}
+}
+
+func TestExecuteInContainerWithEnvironment(t *testing.T) {
+	ClearCache()
+	_, cleanup := setupFakeCommands(t, map[string]string{"docker": fakeDocker})
+	defer cleanup()
+
+	tmp, err := NewTempDir("container_environment")
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	os.Args[0] = path.Join(tmp.originalCwd, "shellflow")
+	defer tmp.Close()
+
+	err = ioutil.WriteFile("shellflow.toml", []byte(`[Environment]
+GREETING = "hello container"
+`), 0644)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	testScript := `echo "$GREETING" > [[a]] #@ container=ubuntu:18.04
+`
+	env := NewEnvironment()
+	builder, err := ParseShellflow(strings.NewReader(testScript), env, make(map[string]interface{}))
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	gen, err := GenerateTaskScripts("container.sf", "", env, builder)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	err = ExecuteLocalSingle(gen)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	data, err := ioutil.ReadFile("a")
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	if string(data) != "hello container\n" {
+		t.Fatalf("bad output: %s", data)
+	}
+}
+
+func TestScriptCommand(t *testing.T) {
+	if v := scriptCommand(&CommandConfiguration{}, "/work", "/work/job001", nil); v != `/bin/bash -o pipefail -e "/work/job001/script.sh"` {
+		t.Fatalf("bad command: %s", v)
+	}
+
+	conf := CommandConfiguration{Container: "ubuntu:18.04", ContainerEngine: "podman", ContainerOption: []string{"--network=none"}}
+	if v := scriptCommand(&conf, "/work", "/wf/job001", map[string]string{"TMPDIR": "/tmp", "JAVA_OPTS": "-Xmx1g"}); v != `podman run --rm -u "$(id -u):$(id -g)" -v "/work:/work" -v "/wf/job001:/wf/job001" -w "/work" -e JAVA_OPTS -e TMPDIR --network=none "ubuntu:18.04" /bin/bash -o pipefail -e "/wf/job001/script.sh"` {
+		t.Fatalf("bad command: %s", v)
+	}
+
+	conf = CommandConfiguration{Singularity: "/images/ubuntu.sif", SingularityOption: []string{"--cleanenv"}}
+	if v := scriptCommand(&conf, "/work", "/wf/job001", nil); v != `singularity exec --bind "/work" --bind "/wf/job001" --pwd "/work" --cleanenv "/images/ubuntu.sif" /bin/bash -o pipefail -e "/wf/job001/script.sh"` {
+		t.Fatalf("bad command: %s", v)
+	}
+
+	if err := (&CommandConfiguration{Container: "ubuntu", ContainerEngine: "lxc"}).Validate(); err == nil {
+		t.Fatalf("unknown container engine should be error")
+	}
+	if err := (&CommandConfiguration{Container: "ubuntu", Singularity: "ubuntu.sif"}).Validate(); err == nil {
+		t.Fatalf("container and singularity should not be used at once")
+	}
+}
+
+const fakeSingularity = `#!/bin/bash
+FAKE_DIR="$(dirname "$0")"
+echo "$@" >> "$FAKE_DIR/singularity.log"
+while [ "$1" != "/bin/bash" ]; do
+    shift
+done
+exec "$@"
+`
+
+func TestExecuteInSingularity(t *testing.T) {
+	ClearCache()
+	fakeDir, cleanup := setupFakeCommands(t, map[string]string{"singularity": fakeSingularity})
+	defer cleanup()
+
+	tmp, err := NewTempDir("singularity")
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	os.Args[0] = path.Join(tmp.originalCwd, "shellflow")
+	defer tmp.Close()
+
+	err = ioutil.WriteFile("image.sif", []byte("image1"), 0644)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	testScript := `echo hello > [[a]] #@ singularity=image.sif
+`
+	run := func() *TaskScripts {
+		ClearCache()
+		env := NewEnvironment()
+		builder, err := ParseShellflow(strings.NewReader(testScript), env, make(map[string]interface{}))
+		if err != nil {
+			t.Fatalf("error: %s", err.Error())
+		}
+
+		gen, err := GenerateTaskScripts("singularity.sf", "", env, builder)
+		if err != nil {
+			t.Fatalf("error: %s", err.Error())
+		}
+
+		err = ExecuteLocalSingle(gen)
+		if err != nil {
+			t.Fatalf("error: %s", err.Error())
+		}
+		return gen
+	}
+
+	gen := run()
+	singularityLog, err := ioutil.ReadFile(path.Join(fakeDir, "singularity.log"))
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	if !strings.HasPrefix(string(singularityLog), "exec --bind ") || !strings.Contains(string(singularityLog), " image.sif /bin/bash -o pipefail -e ") {
+		t.Fatalf("bad singularity argument: %s", singularityLog)
+	}
+
+	log, err := CollectLogsForOneWork(gen.workflowRoot)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	if log.JobLogs[0].State() != JobDone || len(log.JobLogs[0].InputFiles) != 1 || log.JobLogs[0].InputFiles[0].Relpath != "image.sif" {
+		t.Fatalf("bad job log: %s", log.JobLogs[0])
+	}
+
+	// same image can be reused
+	gen = run()
+	if !gen.builder.Tasks[0].ShouldSkip {
+		t.Fatalf("job should be reused")
+	}
+
+	// image is updated
+	err = ioutil.WriteFile("image.sif", []byte("image2"), 0644)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	gen = run()
+	if gen.builder.Tasks[0].ShouldSkip {
+		t.Fatalf("job should not be reused")
+	}
+
+	// another image is used
+	err = ioutil.WriteFile("image3.sif", []byte("image2"), 0644)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	testScript = "echo hello > [[a]] #@ singularity=image3.sif\n"
+	gen = run()
+	if gen.builder.Tasks[0].ShouldSkip {
+		t.Fatalf("job should not be reused")
+	}
+	if reasons := gen.builder.RerunReasons(1); len(reasons) == 0 || reasons[0] != "container is changed" {
+		t.Fatalf("bad reasons: %s", reasons)
+	}
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	condorNodeFileName       = "condor-node.txt"
+	condorSubmitFileName     = "condor.sub"
+	condorDagFileName        = "workflow.dag"
+	condorDagIDFileName      = "condor-dag-id.txt"
+	condorNodeStatusFileName = "condor-node-status.txt"
+)
+
+// DAGMan node status values written in node status file
+const (
+	condorNodeNotReady  = 0
+	condorNodeReady     = 1
+	condorNodePreRun    = 2
+	condorNodeSubmitted = 3
+	condorNodePostRun   = 4
+	condorNodeDone      = 5
+	condorNodeError     = 6
+	condorNodeFutile    = 7
+)
+
+// CondorExecuter submits a workflow to HTCondor as a DAGMan job
+type CondorExecuter struct{}
+
+func init() {
+	RegisterExecuter("condor", &CondorExecuter{})
+}
+
+func (e *CondorExecuter) Submit(ge *TaskScripts) error {
+	return ExecuteInCondor(ge)
+}
+
+func (e *CondorExecuter) FollowUp(jobLogRoot string) (bool, error) {
+	return FollowUpCondor(jobLogRoot)
+}
+
+func (e *CondorExecuter) JobIDFileName() string {
+	return condorNodeFileName
+}
+
+func (e *CondorExecuter) Cancel(jobLogRoot string) (bool, error) {
+	_, err := readJobIDFile(jobLogRoot, condorNodeFileName)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	// DAGMan job is shared by all jobs in a workflow
+	dagID, err := readJobIDFile(path.Dir(jobLogRoot), condorDagIDFileName)
+	if err != nil {
+		return true, err
+	}
+	// held DAGMan job is also removed
+	switch condorJobStatus(dagID) {
+	case "", condorJobRemoved, condorJobCompleted:
+		return true, nil
+	}
+
+	out, err := exec.Command("condor_rm", dagID).CombinedOutput()
+	if err != nil {
+		return true, fmt.Errorf("cannot run condor_rm successfully: %s %s", err.Error(), strings.TrimSpace(string(out)))
+	}
+	return true, nil
+}
+
+func (e *CondorExecuter) Status(jobLogRoot string) (JobState, error) {
+	node, err := readJobIDFile(jobLogRoot, condorNodeFileName)
+	if os.IsNotExist(err) {
+		return JobUnknown, nil
+	} else if err != nil {
+		return JobUnknown, err
+	}
+
+	rc, err := readReturnCode(jobLogRoot)
+	if err == nil {
+		return returnCodeToJobState(rc), nil
+	} else if !os.IsNotExist(err) {
+		return JobUnknown, err
+	}
+
+	status, ok, err := readCondorNodeStatus(path.Dir(jobLogRoot), node)
+	if err != nil {
+		return JobUnknown, err
+	}
+	if !ok {
+		return JobPending, nil
+	}
+
+	switch status {
+	case condorNodeNotReady, condorNodeReady:
+		return JobPending, nil
+	case condorNodePreRun, condorNodeSubmitted, condorNodePostRun:
+		return JobRunning, nil
+	}
+	return JobFailed, nil
+}
+
+// JobStatus values of jobs in HTCondor queue
+const (
+	condorJobRemoved   = "3"
+	condorJobCompleted = "4"
+	condorJobHeld      = "5"
+)
+
+// condorJobStatus returns JobStatus of a job in HTCondor queue. Empty string
+// is returned if the job is not in the queue.
+func condorJobStatus(condorJobID string) string {
+	out, err := exec.Command("condor_q", condorJobID, "-af", "JobStatus").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// isCondorJobActive checks whether a job is in HTCondor queue and is not
+// removed, completed or held.
+func isCondorJobActive(condorJobID string) bool {
+	switch condorJobStatus(condorJobID) {
+	case "", condorJobRemoved, condorJobCompleted, condorJobHeld:
+		return false
+	}
+	return true
+}
+
+var condorClassAdCommentRegexp = regexp.MustCompile("/\\*.*?\\*/")
+
+// parseCondorNodeStatus parses a DAGMan node status file and returns status
+// of each node.
+func parseCondorNodeStatus(data string) map[string]int {
+	result := make(map[string]int)
+
+	var classAdType, node string
+	status := -1
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(condorClassAdCommentRegexp.ReplaceAllString(line, ""))
+		if line == "[" {
+			classAdType, node, status = "", "", -1
+			continue
+		} else if line == "]" {
+			if classAdType == "NodeStatus" && node != "" && status >= 0 {
+				result[node] = status
+			}
+			continue
+		}
+
+		fields := strings.SplitN(strings.TrimSuffix(line, ";"), "=", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		value := strings.TrimSpace(fields[1])
+		switch strings.TrimSpace(fields[0]) {
+		case "Type":
+			classAdType = strings.Trim(value, "\"")
+		case "Node":
+			node = strings.Trim(value, "\"")
+		case "NodeStatus":
+			if v, err := strconv.Atoi(value); err == nil {
+				status = v
+			}
+		}
+	}
+
+	return result
+}
+
+// readCondorNodeStatus reads status of a node from node status file in a
+// workflow log directory. Return false if status of the node is not available.
+func readCondorNodeStatus(workflowLogRoot string, node string) (int, bool, error) {
+	data, err := ioutil.ReadFile(path.Join(workflowLogRoot, condorNodeStatusFileName))
+	if os.IsNotExist(err) {
+		return 0, false, nil
+	} else if err != nil {
+		return 0, false, err
+	}
+	status, ok := parseCondorNodeStatus(string(data))[node]
+	return status, ok, nil
+}
+
+func FollowUpCondor(jobLogRoot string) (bool, error) {
+	_, err := readReturnCode(jobLogRoot)
+	if err == nil {
+		return false, nil
+	} else if !os.IsNotExist(err) {
+		return false, err
+	}
+
+	node, err := readJobIDFile(jobLogRoot, condorNodeFileName)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	workflowLogRoot := path.Dir(jobLogRoot)
+	status, ok, err := readCondorNodeStatus(workflowLogRoot, node)
+	if err != nil {
+		return false, err
+	}
+
+	if ok {
+		switch status {
+		case condorNodeDone, condorNodeError:
+			return true, writeJobResult(jobLogRoot, 1000, "DAGMan node is finished without return code")
+		case condorNodeFutile:
+			return true, writeJobResult(jobLogRoot, 2000, "DAGMan node is not run because dependent node is failed")
+		}
+	}
+
+	// node status file is not written yet or the node is not finished, but
+	// DAGMan job may be removed, held or crashed
+	dagID, err := readJobIDFile(workflowLogRoot, condorDagIDFileName)
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	if err == nil && !isCondorJobActive(dagID) {
+		return true, writeJobResult(jobLogRoot, 1000, "DAGMan job is removed, held or vanished")
+	}
+	return true, nil
+}
+
+var condorSubmitClusterRegexp = regexp.MustCompile("submitted to cluster (\\d+)")
+
+func ExecuteInCondor(ge *TaskScripts) error {
+	jobNameBase := jobNameReplace.ReplaceAllString(ge.jobName, "_")
+	workflowRoot := Abs(ge.workflowRoot)
+	resourceTemplate, err := LoadResourceTemplate("condor")
+	if err != nil {
+		return err
+	}
+
+	var dag strings.Builder
+	submitted := make(map[int]bool)
+
+	for _, v := range ge.builder.Tasks {
+		if v.ShouldSkip {
+			fmt.Printf("skipping: %s\n", v.ShellScript)
+			continue
+		}
+
+		if v.CommandConfiguration.RunImmediate {
+			err := executeImmediateTask(ge, v)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		scriptInfo := ge.scripts[v.ID]
+		jobRoot := Abs(scriptInfo.JobRoot)
+		node := fmt.Sprintf("job%03d", v.ID)
+
+		// write submit description
+		var submit strings.Builder
+		fmt.Fprintf(&submit, "universe = vanilla\n")
+		fmt.Fprintf(&submit, "executable = /bin/bash\n")
+		fmt.Fprintf(&submit, "arguments = \"%s\"\n", Abs(scriptInfo.RunScriptPath))
+		fmt.Fprintf(&submit, "initialdir = %s\n", jobRoot)
+		fmt.Fprintf(&submit, "output = %s\n", path.Join(jobRoot, "run.stdout"))
+		fmt.Fprintf(&submit, "error = %s\n", path.Join(jobRoot, "run.stderr"))
+		fmt.Fprintf(&submit, "log = %s\n", path.Join(workflowRoot, "condor.log"))
+		fmt.Fprintf(&submit, "batch_name = sf-%s\n", jobNameBase)
+		resourceOptions, err := resourceTemplate.Options(&v.CommandConfiguration)
+		if err != nil {
+			return err
+		}
+		for _, x := range resourceOptions {
+			fmt.Fprintf(&submit, "%s\n", x)
+		}
+		for _, x := range v.CommandConfiguration.CondorOption {
+			fmt.Fprintf(&submit, "%s\n", x)
+		}
+		fmt.Fprintf(&submit, "queue\n")
+
+		submitPath := path.Join(jobRoot, condorSubmitFileName)
+		err = ioutil.WriteFile(submitPath, []byte(submit.String()), 0644)
+		if err != nil {
+			return fmt.Errorf("cannot write HTCondor submit description: %s", err.Error())
+		}
+		err = ioutil.WriteFile(path.Join(jobRoot, condorNodeFileName), []byte(node+"\n"), 0644)
+		if err != nil {
+			return fmt.Errorf("Cannot open HTCondor node log file: %s", err.Error())
+		}
+
+		fmt.Fprintf(&dag, "JOB %s %s\n", node, submitPath)
+		for _, d := range v.DependentTaskID {
+			if submitted[d] {
+				fmt.Fprintf(&dag, "PARENT job%03d CHILD %s\n", d, node)
+			}
+		}
+		submitted[v.ID] = true
+	}
+
+	if len(submitted) == 0 {
+		return nil
+	}
+
+	fmt.Fprintf(&dag, "NODE_STATUS_FILE %s 30\n", path.Join(workflowRoot, condorNodeStatusFileName))
+
+	dagPath := path.Join(workflowRoot, condorDagFileName)
+	err = ioutil.WriteFile(dagPath, []byte(dag.String()), 0644)
+	if err != nil {
+		return fmt.Errorf("cannot write DAG file: %s", err.Error())
+	}
+
+	// remove node status of previous execution
+	err = os.Remove(path.Join(workflowRoot, condorNodeStatusFileName))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	// run HTCondor
+	cmd := exec.Command("condor_submit_dag", "-force", "-batch-name", "sf-"+jobNameBase, dagPath)
+	out, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("cannot run condor_submit_dag successfully: %s", err.Error())
+	}
+	match := condorSubmitClusterRegexp.FindStringSubmatch(string(out))
+	if match == nil {
+		return fmt.Errorf("cannot read cluster ID from condor_submit_dag output: %s", out)
+	}
+
+	err = ioutil.WriteFile(path.Join(workflowRoot, condorDagIDFileName), []byte(match[1]+"\n"), 0644)
+	if err != nil {
+		return fmt.Errorf("Cannot open DAGMan job ID log file: %s", err.Error())
+	}
+	fmt.Printf("Submit ID:%s  : %s\n", match[1], dagPath)
+
+	return nil
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const fakeCondorSubmitDag = `#!/bin/bash
+FAKE_DIR="$(dirname "$0")"
+DAG="${@: -1}"
+echo "$@" >> "$FAKE_DIR/condor_submit_dag.log"
+cp "$DAG" "$FAKE_DIR/submitted.dag"
+STATUS_FILE=$(awk '$1 == "NODE_STATUS_FILE" {print $2}' "$DAG")
+awk '$1 == "JOB" {print $2, $3}' "$DAG" | while read NODE SUBMIT; do
+    SCRIPT=$(sed -n 's/^arguments = "\(.*\)"$/\1/p' "$SUBMIT")
+    /bin/bash "$SCRIPT" > /dev/null 2>&1
+    printf '[\n  Type = "NodeStatus";\n  Node = "%s";\n  NodeStatus = 5; /* "STATUS_DONE" */\n]\n' "$NODE" >> "$STATUS_FILE"
+done
+echo "Submitting job(s)."
+echo "1 job(s) submitted to cluster 500."
+`
+
+const fakeCondorQ = `#!/bin/bash
+if [ "$1" = "600" ]; then
+    echo 2
+elif [ "$1" = "601" ]; then
+    # held
+    echo 5
+fi
+`
+
+func TestExecuteInCondor(t *testing.T) {
+	ClearCache()
+	fakeDir, cleanup := setupFakeCommands(t, map[string]string{"condor_submit_dag": fakeCondorSubmitDag, "condor_q": fakeCondorQ})
+	defer cleanup()
+
+	tmp, err := NewTempDir("condor")
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	os.Args[0] = path.Join(tmp.originalCwd, "shellflow")
+	defer tmp.Close()
+
+	testScript := `echo 1 > [[a]]
+echo 2 > [[b]]
+cat ((a)) ((b)) > [[c]]
+`
+	env := NewEnvironment()
+	builder, err := ParseShellflow(strings.NewReader(testScript), env, make(map[string]interface{}))
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	gen, err := GenerateTaskScripts("condor.sf", "", env, builder)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	err = ExecuteInCondor(gen)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	dag, err := ioutil.ReadFile(path.Join(fakeDir, "submitted.dag"))
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	if !strings.Contains(string(dag), "PARENT job001 CHILD job003\nPARENT job002 CHILD job003\n") {
+		t.Fatalf("bad DAG file: %s", dag)
+	}
+
+	dagID, err := readJobIDFile(gen.workflowRoot, condorDagIDFileName)
+	if err != nil || dagID != "500" {
+		t.Fatalf("bad DAG job ID: %s %s", dagID, err)
+	}
+
+	log, err := CollectLogsForOneWork(gen.workflowRoot)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	for _, v := range log.JobLogs {
+		if v.State() != JobDone {
+			t.Fatalf("bad job state: %s", v)
+		}
+	}
+	if log.JobLogs[2].Backend != "condor" || log.JobLogs[2].JobID != "job003" {
+		t.Fatalf("bad node: %s %s", log.JobLogs[2].Backend, log.JobLogs[2].JobID)
+	}
+}
+
+func TestParseCondorNodeStatus(t *testing.T) {
+	status := parseCondorNodeStatus(`[
+  Type = "DagStatus";
+  DagFiles = {
+    "workflow.dag"
+  };
+  DagStatus = 3; /* "STATUS_SUBMITTED ()" */
+]
+[
+  Type = "NodeStatus";
+  Node = "job001";
+  NodeStatus = 5; /* "STATUS_DONE" */
+  StatusDetails = "";
+]
+[
+  Type = "NodeStatus";
+  Node = "job002";
+  NodeStatus = 6; /* "STATUS_ERROR" */
+  StatusDetails = "Job proc (1.0.0) failed with status 1";
+]
+[
+  Type = "NodeStatus";
+  Node = "job003";
+  NodeStatus = 7; /* "STATUS_FUTILE" */
+]
+[
+  Type = "StatusEnd";
+  NextUpdate = 0; /* "none" */
+]
+`)
+	expected := map[string]int{"job001": condorNodeDone, "job002": condorNodeError, "job003": condorNodeFutile}
+	if !reflect.DeepEqual(status, expected) {
+		t.Fatalf("bad node status: %v", status)
+	}
+}
+
+func TestFollowUpCondor(t *testing.T) {
+	_, cleanup := setupFakeCommands(t, map[string]string{"condor_q": fakeCondorQ})
+	defer cleanup()
+
+	workflowRoot, err := ioutil.TempDir("", "followup_condor")
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	defer os.RemoveAll(workflowRoot)
+
+	jobRoot := path.Join(workflowRoot, "job001")
+	err = os.MkdirAll(jobRoot, 0755)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	// not a condor job
+	done, err := FollowUpCondor(jobRoot)
+	if err != nil || done {
+		t.Fatalf("bad follow up result: %v %s", done, err)
+	}
+
+	// DAGMan job is running but node status file is not written yet
+	err = ioutil.WriteFile(path.Join(jobRoot, condorNodeFileName), []byte("job001\n"), 0644)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	err = ioutil.WriteFile(path.Join(workflowRoot, condorDagIDFileName), []byte("600\n"), 0644)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	done, err = FollowUpCondor(jobRoot)
+	if err != nil || !done {
+		t.Fatalf("bad follow up result: %v %s", done, err)
+	}
+	if state, err := JobStatus(jobRoot); err != nil || state != JobPending {
+		t.Fatalf("bad state: %s %s", state, err)
+	}
+
+	// node is not run because parent is failed
+	err = ioutil.WriteFile(path.Join(workflowRoot, condorNodeStatusFileName), []byte("[\n  Type = \"NodeStatus\";\n  Node = \"job001\";\n  NodeStatus = 7;\n]\n"), 0644)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	done, err = FollowUpCondor(jobRoot)
+	if err != nil || !done {
+		t.Fatalf("bad follow up result: %v %s", done, err)
+	}
+	rc, err := ioutil.ReadFile(path.Join(jobRoot, "rc"))
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	if string(rc) != "2000" {
+		t.Fatalf("bad rc: %s", rc)
+	}
+
+	// node is submitted and DAGMan job is running
+	err = os.Remove(path.Join(jobRoot, "rc"))
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	err = ioutil.WriteFile(path.Join(workflowRoot, condorNodeStatusFileName), []byte("[\n  Type = \"NodeStatus\";\n  Node = \"job001\";\n  NodeStatus = 3;\n]\n"), 0644)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	done, err = FollowUpCondor(jobRoot)
+	if err != nil || !done {
+		t.Fatalf("bad follow up result: %v %s", done, err)
+	}
+	if _, err := os.Stat(path.Join(jobRoot, "rc")); !os.IsNotExist(err) {
+		t.Fatalf("rc should not be created: %s", err)
+	}
+
+	// DAGMan job is held or removed after node status file is written
+	for _, dagID := range []string{"601", "602"} {
+		err = ioutil.WriteFile(path.Join(workflowRoot, condorDagIDFileName), []byte(dagID+"\n"), 0644)
+		if err != nil {
+			t.Fatalf("error: %s", err.Error())
+		}
+		done, err = FollowUpCondor(jobRoot)
+		if err != nil || !done {
+			t.Fatalf("bad follow up result: %v %s", done, err)
+		}
+		rc, err = ioutil.ReadFile(path.Join(jobRoot, "rc"))
+		if err != nil {
+			t.Fatalf("error: %s", err.Error())
+		}
+		if string(rc) != "1000" {
+			t.Fatalf("bad rc: %s", rc)
+		}
+		err = os.Remove(path.Join(jobRoot, "rc"))
+		if err != nil {
+			t.Fatalf("error: %s", err.Error())
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+type localTaskResult struct {
+	task *ShellTask
+	err  error
+}
+
+// ExecuteLocalParallel runs tasks in local machine with a pool of workers.
+// A task is started when all dependent tasks are finished successfully.
+// Once a task is failed, no more tasks are started, but running tasks are
+// waited until they are finished.
+func ExecuteLocalParallel(ge *TaskScripts, jobs int) error {
+	if jobs <= 1 {
+		return ExecuteLocalSingle(ge)
+	}
+
+	budget, err := LoadLocalResourceBudget()
+	if err != nil {
+		return err
+	}
+	return executeLocalParallelWithBudget(ge, jobs, budget)
+}
+
+// executeLocalParallelWithBudget runs tasks in parallel while total of
+// requested CPUs and memory of running tasks does not exceed the budget.
+// A task which requests more than the budget is run when no other task is running.
+func executeLocalParallelWithBudget(ge *TaskScripts, jobs int, budget *LocalResourceBudget) error {
+	defer handleLocalSignals()()
+
+	originalWorkDir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	err = os.Chdir(ge.env.workDir)
+	if err != nil {
+		return err
+	}
+	defer os.Chdir(originalWorkDir)
+
+	finished := make(map[int]bool)
+	pending := make([]*ShellTask, 0)
+	for _, v := range ge.builder.Tasks {
+		if v.ShouldSkip {
+			fmt.Printf("skipping: %s\n", v.ShellScript)
+			finished[v.ID] = true
+			continue
+		}
+		pending = append(pending, v)
+	}
+
+	// dependent tasks which are not a part of this workflow are treated as finished
+	inWorkflow := make(map[int]bool)
+	for _, v := range ge.builder.Tasks {
+		inWorkflow[v.ID] = true
+	}
+
+	isReady := func(task *ShellTask) bool {
+		for _, d := range task.DependentTaskID {
+			if inWorkflow[d] && !finished[d] {
+				return false
+			}
+		}
+		return true
+	}
+
+	taskCPU := func(task *ShellTask) int {
+		if task.CommandConfiguration.CPU > 0 {
+			return task.CommandConfiguration.CPU
+		}
+		return 1
+	}
+
+	taskMemory := func(task *ShellTask) int64 {
+		memory, _ := task.CommandConfiguration.MemoryRequest()
+		return memory.Byte()
+	}
+
+	results := make(chan localTaskResult)
+	running := 0
+	usedCPU := 0
+	var usedMemory int64
+	var finalErr error
+
+	fits := func(task *ShellTask) bool {
+		if running == 0 {
+			return true
+		}
+		if usedCPU+taskCPU(task) > budget.CPU {
+			return false
+		}
+		if budget.Memory.Byte() > 0 && usedMemory+taskMemory(task) > budget.Memory.Byte() {
+			return false
+		}
+		return true
+	}
+
+	for {
+		for finalErr == nil && running < jobs {
+			next := -1
+			for i, v := range pending {
+				if isReady(v) && fits(v) {
+					next = i
+					break
+				}
+			}
+			if next < 0 {
+				break
+			}
+
+			task := pending[next]
+			pending = append(pending[:next], pending[next+1:]...)
+			running++
+			usedCPU += taskCPU(task)
+			usedMemory += taskMemory(task)
+			go func(task *ShellTask) {
+				results <- localTaskResult{task: task, err: ExecuteLocalSingleOneTask(ge, task)}
+			}(task)
+		}
+
+		if running == 0 {
+			break
+		}
+
+		result := <-results
+		running--
+		usedCPU -= taskCPU(result.task)
+		usedMemory -= taskMemory(result.task)
+		if result.err != nil {
+			if finalErr == nil {
+				finalErr = result.err
+			}
+		} else {
+			finished[result.task.ID] = true
+		}
+	}
+
+	if finalErr == nil && len(pending) > 0 {
+		finalErr = fmt.Errorf("Cannot resolve task dependency: %d tasks are not started", len(pending))
+	}
+
+	for _, v := range pending {
+		scriptInfo := ge.scripts[v.ID]
+		if _, err := writeReturnCodeIfNotExist(scriptInfo.JobRoot, 2000); err != nil {
+			return err
+		}
+	}
+
+	return finalErr
+}
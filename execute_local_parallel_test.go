@@ -0,0 +1,267 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecuteLocalParallel(t *testing.T) {
+	ClearCache()
+	tmp, err := NewTempDir("local_parallel")
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	os.Args[0] = path.Join(tmp.originalCwd, "shellflow")
+	defer tmp.Close()
+
+	testScript := `echo 1 > [[a]]
+cat ((a)) > [[b]]; sleep 1
+cat ((a)) > [[c]]; sleep 1
+cat ((b)) ((c)) > [[d]]
+`
+	env := NewEnvironment()
+	builder, err := ParseShellflow(strings.NewReader(testScript), env, make(map[string]interface{}))
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	gen, err := GenerateTaskScripts("parallel.sf", "", env, builder)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	err = ExecuteLocalParallel(gen, 4)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	data, err := ioutil.ReadFile("d")
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	if string(data) != "1\n1\n" {
+		t.Fatalf("bad result: %s", data)
+	}
+
+	log, err := CollectLogsForOneWork(gen.workflowRoot)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	for _, v := range log.JobLogs {
+		if v.State() != JobDone {
+			t.Fatalf("bad job state: %s", v)
+		}
+		if v.ResourceUsage == nil || v.ResourceUsage.WallTime <= 0 || v.ResourceUsage.MaxRSS.Byte() <= 0 {
+			t.Fatalf("bad resource usage: %s", v.ResourceUsage)
+		}
+	}
+	if v := log.JobLogs[1].ResourceUsage.WallTime; v < 1 {
+		t.Fatalf("bad wall time: %f", v)
+	}
+	if summary := log.ResourceUsageSummary(); !strings.Contains(summary, "|JobDone     |cat a > b; sleep 1\n") {
+		t.Fatalf("bad resource usage summary: %s", summary)
+	}
+}
+
+func TestExecuteLocalParallelFail(t *testing.T) {
+	ClearCache()
+	tmp, err := NewTempDir("local_parallel_fail")
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	os.Args[0] = path.Join(tmp.originalCwd, "shellflow")
+	defer tmp.Close()
+
+	testScript := `echo 1 > [[a]]; exit 1
+echo 2 > [[b]]
+cat ((a)) ((b)) > [[c]]
+`
+	env := NewEnvironment()
+	builder, err := ParseShellflow(strings.NewReader(testScript), env, make(map[string]interface{}))
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	gen, err := GenerateTaskScripts("parallel.sf", "", env, builder)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	err = ExecuteLocalParallel(gen, 4)
+	if err == nil || !IsExecutionError(err) {
+		t.Fatalf("execution error should be returned: %s", err)
+	}
+
+	if _, err := os.Stat("c"); !os.IsNotExist(err) {
+		t.Fatalf("dependent task should not be started: %s", err)
+	}
+
+	data, err := ioutil.ReadFile(path.Join(gen.scripts[3].JobRoot, "rc"))
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	if string(data) != "2000" {
+		t.Fatalf("bad rc: %s", data)
+	}
+}
+
+func TestExecuteLocalTimeout(t *testing.T) {
+	ClearCache()
+	tmp, err := NewTempDir("local_timeout")
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	os.Args[0] = path.Join(tmp.originalCwd, "shellflow")
+	defer tmp.Close()
+
+	err = ioutil.WriteFile("shellflow.toml", []byte(`Timeout = "1s"
+
+[[Command]]
+RegExp = "quick"
+Timeout = "1m"
+`), 0644)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	testScript := `echo quick > [[a]]; sleep 2
+sleep 30 & sleep 30; echo 1 > [[b]]
+`
+	env := NewEnvironment()
+	builder, err := ParseShellflow(strings.NewReader(testScript), env, make(map[string]interface{}))
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	gen, err := GenerateTaskScripts("timeout.sf", "", env, builder)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	start := time.Now()
+	err = ExecuteLocalParallel(gen, 2)
+	if err == nil || !IsExecutionError(err) {
+		t.Fatalf("execution error should be returned: %s", err)
+	}
+	if d := time.Since(start); d > 20*time.Second {
+		t.Fatalf("timeout does not work: %s", d)
+	}
+
+	log, err := CollectLogsForOneWork(gen.workflowRoot)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	if log.JobLogs[0].State() != JobDone {
+		t.Fatalf("bad job state: %s", log.JobLogs[0])
+	}
+	if log.JobLogs[1].State() != JobTimeout || log.JobLogs[1].ExitCode != TimeoutExitCode || log.JobLogs[1].Reason != "Killed by timeout after 1s" {
+		t.Fatalf("bad job state: %s %s", log.JobLogs[1].State(), log.JobLogs[1].Reason)
+	}
+}
+
+func TestExecuteLocalTimeoutIgnoringTerm(t *testing.T) {
+	ClearCache()
+	tmp, err := NewTempDir("local_timeout_ignore_term")
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	os.Args[0] = path.Join(tmp.originalCwd, "shellflow")
+	defer tmp.Close()
+
+	originalGracePeriod := localKillGracePeriod
+	localKillGracePeriod = time.Second
+	defer func() { localKillGracePeriod = originalGracePeriod }()
+
+	err = ioutil.WriteFile("shellflow.toml", []byte(`Timeout = "1s"
+`), 0644)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	// a child process ignores SIGTERM
+	testScript := `(trap '' TERM; sleep 4; echo late > late.txt); echo 1 > [[a]]
+`
+	env := NewEnvironment()
+	builder, err := ParseShellflow(strings.NewReader(testScript), env, make(map[string]interface{}))
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	gen, err := GenerateTaskScripts("timeout.sf", "", env, builder)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	start := time.Now()
+	err = ExecuteLocalSingle(gen)
+	if err == nil || !IsExecutionError(err) {
+		t.Fatalf("execution error should be returned: %s", err)
+	}
+	// killed processes may remain as zombies until they are reaped by init
+	if d := time.Since(start); d < 2*time.Second || d > 10*time.Second {
+		t.Fatalf("result should be written after SIGKILL: %s", d)
+	}
+
+	log, err := CollectLogsForOneWork(gen.workflowRoot)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	if log.JobLogs[0].State() != JobTimeout {
+		t.Fatalf("bad job state: %s", log.JobLogs[0].State())
+	}
+
+	if d := 5*time.Second - time.Since(start); d > 0 {
+		time.Sleep(d)
+	}
+	if _, err := os.Stat("late.txt"); !os.IsNotExist(err) {
+		t.Fatalf("child process ignoring SIGTERM should be killed: %v", err)
+	}
+}
+
+func TestExecuteLocalParallelBudget(t *testing.T) {
+	ClearCache()
+	tmp, err := NewTempDir("local_parallel_budget")
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	os.Args[0] = path.Join(tmp.originalCwd, "shellflow")
+	defer tmp.Close()
+
+	testScript := `echo 1 > [[a]]; sleep 1
+echo 2 > [[b]]; sleep 1
+`
+	env := NewEnvironment()
+	builder, err := ParseShellflow(strings.NewReader(testScript), env, make(map[string]interface{}))
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	for _, v := range builder.Tasks {
+		v.CommandConfiguration.CPU = 2
+		v.CommandConfiguration.Memory = "1G"
+	}
+
+	gen, err := GenerateTaskScripts("parallel.sf", "", env, builder)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	// tasks cannot run at once because of memory
+	start := time.Now()
+	err = executeLocalParallelWithBudget(gen, 4, &LocalResourceBudget{CPU: 4, Memory: mustParseNewMemory("1.5G")})
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	if elapsed := time.Since(start); elapsed < 2*time.Second {
+		t.Fatalf("tasks should be run one by one: %s", elapsed)
+	}
+
+	for _, v := range []string{"a", "b"} {
+		if _, err := os.Stat(v); err != nil {
+			t.Fatalf("error: %s", err.Error())
+		}
+	}
+}
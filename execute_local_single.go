@@ -3,14 +3,173 @@ package main
 import (
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 )
 
 var localRunPidFile = "local-run-pid.txt"
 
+// LocalExecuter runs tasks in local machine
+type LocalExecuter struct{}
+
+func init() {
+	RegisterExecuter("local", &LocalExecuter{})
+}
+
+func (e *LocalExecuter) Submit(ge *TaskScripts) error {
+	return ExecuteLocalParallel(ge, ge.env.localJobs)
+}
+
+func (e *LocalExecuter) FollowUp(jobLogRoot string) (bool, error) {
+	return FollowUpLocalSingle(jobLogRoot)
+}
+
+func (e *LocalExecuter) Cancel(jobLogRoot string) (bool, error) {
+	pid, err := readLocalRunPid(jobLogRoot)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	if !isLocalProcessAlive(pid) {
+		return true, nil
+	}
+
+	// kill whole process group if the job is a process group leader
+	target := pid
+	if pgid, err := syscall.Getpgid(pid); err == nil && pgid == pid {
+		target = -pid
+	}
+	err = syscall.Kill(target, syscall.SIGTERM)
+	if err != nil {
+		return true, fmt.Errorf("Cannot kill process %d: %s", pid, err.Error())
+	}
+	return true, nil
+}
+
+func (e *LocalExecuter) Status(jobLogRoot string) (JobState, error) {
+	pid, err := readLocalRunPid(jobLogRoot)
+	if os.IsNotExist(err) {
+		return JobUnknown, nil
+	} else if err != nil {
+		return JobUnknown, err
+	}
+
+	rc, err := readReturnCode(jobLogRoot)
+	if err == nil {
+		return returnCodeToJobState(rc), nil
+	} else if !os.IsNotExist(err) {
+		return JobUnknown, err
+	}
+
+	if isLocalProcessAlive(pid) {
+		return JobRunning, nil
+	}
+	return JobFailed, nil
+}
+
+func readLocalRunPid(jobLogRoot string) (int, error) {
+	data, err := ioutil.ReadFile(path.Join(jobLogRoot, localRunPidFile))
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("Cannot read pid: %s", err.Error())
+	}
+	return pid, nil
+}
+
+func isLocalProcessAlive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return process.Signal(syscall.Signal(0)) == nil
+}
+
+// localKillGracePeriod is a period between SIGTERM and SIGKILL
+var localKillGracePeriod = 10 * time.Second
+
+// killLocalProcessGroup sends SIGTERM to a process group, and sends SIGKILL
+// if the process group is still alive after grace period. The returned timer
+// must be passed to waitLocalProcessGroup after the process group leader is
+// waited.
+func killLocalProcessGroup(pgid int) *time.Timer {
+	syscall.Kill(-pgid, syscall.SIGTERM)
+	return time.AfterFunc(localKillGracePeriod, func() {
+		syscall.Kill(-pgid, syscall.SIGKILL)
+	})
+}
+
+// waitLocalProcessGroup waits until all processes in a process group killed
+// by killLocalProcessGroup exit. The group leader exits soon after SIGTERM,
+// but its children may ignore SIGTERM, so SIGKILL is still sent to them
+// after grace period. The timer is stopped once the group is empty, because
+// the process group ID may be reused after that.
+func waitLocalProcessGroup(pgid int, timer *time.Timer) {
+	deadline := time.Now().Add(localKillGracePeriod + 5*time.Second)
+	for syscall.Kill(-pgid, 0) == nil && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+	}
+	timer.Stop()
+}
+
+// localJobs are functions to interrupt running local jobs, keyed by process
+// group ID. No job is started after interrupted is set.
+var localJobs = struct {
+	sync.Mutex
+	interrupted bool
+	interrupt   map[int]func()
+}{interrupt: make(map[int]func())}
+
+// handleLocalSignals interrupts all running local jobs when SIGINT or SIGTERM
+// is received, because jobs in their own process groups do not receive
+// signals from a terminal. Executers finish as usual after jobs are killed.
+// shellflow exits immediately on a second signal. The returned function
+// stops handling signals.
+func handleLocalSignals() func() {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case sig := <-signals:
+			fmt.Fprintf(os.Stderr, "Received %s: killing running jobs\n", sig)
+			localJobs.Lock()
+			localJobs.interrupted = true
+			for _, interrupt := range localJobs.interrupt {
+				interrupt()
+			}
+			localJobs.Unlock()
+		case <-done:
+			return
+		}
+		select {
+		case <-signals:
+			os.Exit(1)
+		case <-done:
+		}
+	}()
+	return func() {
+		signal.Stop(signals)
+		close(done)
+		localJobs.Lock()
+		localJobs.interrupted = false
+		localJobs.Unlock()
+	}
+}
+
 type executationError struct {
 	message   string
 	exitCode  int
@@ -72,6 +231,8 @@ func FollowUpLocalSingle(jobLogRoot string) (bool, error) {
 
 // ExecuteLocalSingle runs tasks in local machine and single thread
 func ExecuteLocalSingle(ge *TaskScripts) error {
+	defer handleLocalSignals()()
+
 	originalWorkDir, err := os.Getwd()
 	if err != nil {
 		return err
@@ -87,12 +248,9 @@ func ExecuteLocalSingle(ge *TaskScripts) error {
 	for _, v := range ge.builder.Tasks {
 		if finalErr != nil {
 			scriptInfo := ge.scripts[v.ID]
-			rc, err := os.OpenFile(path.Join(scriptInfo.JobRoot, "rc"), os.O_CREATE|os.O_WRONLY, 0644)
-			if err != nil {
+			if _, err := writeReturnCodeIfNotExist(scriptInfo.JobRoot, 2000); err != nil {
 				return err
 			}
-			defer rc.Close()
-			fmt.Fprintf(rc, "2000")
 			continue
 		}
 
@@ -114,9 +272,26 @@ func ExecuteLocalSingleOneTask(ge *TaskScripts, v *ShellTask) error {
 	scriptInfo := ge.scripts[v.ID]
 	args := []string{scriptInfo.RunScriptPath}
 
+	// a job is cancelled before start
+	if rc, err := readReturnCode(scriptInfo.JobRoot); err == nil && rc == CancelledExitCode {
+		return &executationError{
+			message:   fmt.Sprintf("cancelled: %s", scriptInfo.RunScriptPath),
+			exitCode:  CancelledExitCode,
+			jobRoot:   scriptInfo.JobRoot,
+			shellTask: v,
+		}
+	}
+
 	fmt.Printf("%s\n", v.ShellScript)
 
 	cmd := exec.Command("/bin/bash", args...)
+	// create new process group to kill all child processes at once
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	timeout, err := v.CommandConfiguration.TimeoutDuration()
+	if err != nil {
+		return err
+	}
 
 	stdout, err := os.OpenFile(path.Join(scriptInfo.JobRoot, "run.stdout"), os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
@@ -146,11 +321,42 @@ func ExecuteLocalSingleOneTask(ge *TaskScripts, v *ShellTask) error {
 		return err
 	}
 
+	// a job is not started after shellflow is interrupted
+	localJobs.Lock()
+	if localJobs.interrupted {
+		localJobs.Unlock()
+		return interruptedLocalJob(scriptInfo.JobRoot, v)
+	}
+	startTime := time.Now()
 	err = cmd.Start()
 	if err != nil {
+		localJobs.Unlock()
 		return err
 	}
 
+	var killMutex sync.Mutex
+	var killTimer *time.Timer
+	var waited bool
+	var timedOut, interrupted int32
+	kill := func(flag *int32) {
+		killMutex.Lock()
+		defer killMutex.Unlock()
+		if waited {
+			return
+		}
+		atomic.StoreInt32(flag, 1)
+		if killTimer == nil {
+			killTimer = killLocalProcessGroup(cmd.Process.Pid)
+		}
+	}
+	localJobs.interrupt[cmd.Process.Pid] = func() { kill(&interrupted) }
+	localJobs.Unlock()
+
+	if timeout > 0 {
+		timer := time.AfterFunc(timeout, func() { kill(&timedOut) })
+		defer timer.Stop()
+	}
+
 	if _, err = fmt.Fprintf(pid, "%d", cmd.Process.Pid); err != nil {
 		return err
 	}
@@ -163,7 +369,43 @@ func ExecuteLocalSingleOneTask(ge *TaskScripts, v *ShellTask) error {
 		return err
 	}
 
-	if err = cmd.Wait(); err != nil {
+	err = cmd.Wait()
+
+	localJobs.Lock()
+	delete(localJobs.interrupt, cmd.Process.Pid)
+	localJobs.Unlock()
+	killMutex.Lock()
+	waited = true
+	killMutex.Unlock()
+	// a result is written after all killed processes exit
+	if killTimer != nil {
+		waitLocalProcessGroup(cmd.Process.Pid, killTimer)
+	}
+
+	if cmd.ProcessState != nil {
+		if err := WriteResourceUsage(scriptInfo.JobRoot, NewResourceUsageFromProcessState(cmd.ProcessState, time.Since(startTime))); err != nil {
+			return err
+		}
+	}
+
+	if atomic.LoadInt32(&interrupted) != 0 {
+		return interruptedLocalJob(scriptInfo.JobRoot, v)
+	}
+
+	if err != nil {
+		if atomic.LoadInt32(&timedOut) != 0 {
+			err = writeJobResult(scriptInfo.JobRoot, TimeoutExitCode, fmt.Sprintf("Killed by timeout after %s", timeout))
+			if err != nil {
+				return err
+			}
+			return &executationError{
+				message:   fmt.Sprintf("timeout %s: %s", timeout, scriptInfo.RunScriptPath),
+				exitCode:  TimeoutExitCode,
+				jobRoot:   scriptInfo.JobRoot,
+				shellTask: v,
+			}
+		}
+
 		exitCode := 1000
 		status, ok := cmd.ProcessState.Sys().(syscall.WaitStatus)
 		if ok {
@@ -180,3 +422,26 @@ func ExecuteLocalSingleOneTask(ge *TaskScripts, v *ShellTask) error {
 
 	return nil
 }
+
+// executeImmediateTask runs a task with RunImmediate in local machine while
+// tasks are submitted to a job scheduler. The task is killed on SIGINT or
+// SIGTERM like tasks run by local executers.
+func executeImmediateTask(ge *TaskScripts, task *ShellTask) error {
+	defer handleLocalSignals()()
+	return ExecuteLocalSingleOneTask(ge, task)
+}
+
+// interruptedLocalJob records a job killed or not started because shellflow
+// is interrupted as a cancelled job.
+func interruptedLocalJob(jobRoot string, task *ShellTask) error {
+	err := writeJobResult(jobRoot, CancelledExitCode, "Interrupted by signal")
+	if err != nil {
+		return err
+	}
+	return &executationError{
+		message:   fmt.Sprintf("interrupted: %s", jobRoot),
+		exitCode:  CancelledExitCode,
+		jobRoot:   jobRoot,
+		shellTask: task,
+	}
+}
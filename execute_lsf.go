@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const lsfJobIDFileName = "lsf-jobid.txt"
+
+// LsfExecuter submits tasks to IBM Spectrum LSF
+type LsfExecuter struct{}
+
+func init() {
+	RegisterExecuter("lsf", &LsfExecuter{})
+}
+
+func (e *LsfExecuter) Submit(ge *TaskScripts) error {
+	return ExecuteInLsf(ge)
+}
+
+func (e *LsfExecuter) FollowUp(jobLogRoot string) (bool, error) {
+	return FollowUpLsf(jobLogRoot)
+}
+
+func (e *LsfExecuter) JobIDFileName() string {
+	return lsfJobIDFileName
+}
+
+func (e *LsfExecuter) Cancel(jobLogRoot string) (bool, error) {
+	lsfJobID, err := readJobIDFile(jobLogRoot, lsfJobIDFileName)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	out, err := exec.Command("bkill", lsfJobID).CombinedOutput()
+	if err != nil {
+		return true, fmt.Errorf("cannot run bkill successfully: %s %s", err.Error(), strings.TrimSpace(string(out)))
+	}
+	return true, nil
+}
+
+func (e *LsfExecuter) Status(jobLogRoot string) (JobState, error) {
+	lsfJobID, err := readJobIDFile(jobLogRoot, lsfJobIDFileName)
+	if os.IsNotExist(err) {
+		return JobUnknown, nil
+	} else if err != nil {
+		return JobUnknown, err
+	}
+
+	rc, err := readReturnCode(jobLogRoot)
+	if err == nil {
+		return returnCodeToJobState(rc), nil
+	} else if !os.IsNotExist(err) {
+		return JobUnknown, err
+	}
+
+	if isLsfJobActive(lsfJobID) {
+		return JobRunning, nil
+	}
+	return JobFailed, nil
+}
+
+var lsfActiveStates = map[string]bool{
+	"PEND":  true,
+	"PROV":  true,
+	"RUN":   true,
+	"PSUSP": true,
+	"USUSP": true,
+	"SSUSP": true,
+	"WAIT":  true,
+}
+
+// isLsfJobActive checks whether a job is still managed by LSF with bjobs.
+func isLsfJobActive(lsfJobID string) bool {
+	out, err := exec.Command("bjobs", "-w", lsfJobID).Output()
+	if err != nil {
+		return false
+	}
+
+	// JOBID USER STAT QUEUE FROM_HOST EXEC_HOST JOB_NAME SUBMIT_TIME
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 3 && fields[0] == lsfJobID {
+			return lsfActiveStates[fields[2]]
+		}
+	}
+	return false
+}
+
+func FollowUpLsf(jobLogRoot string) (bool, error) {
+	_, err := readReturnCode(jobLogRoot)
+	if err == nil {
+		return false, nil
+	} else if !os.IsNotExist(err) {
+		return false, err
+	}
+
+	lsfJobID, err := readJobIDFile(jobLogRoot, lsfJobIDFileName)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	if !isLsfJobActive(lsfJobID) {
+		if err := writeJobResult(jobLogRoot, 1000, "LSF job is finished without return code"); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+var bsubJobIDRegexp = regexp.MustCompile("Job <(\\d+)>")
+
+func ExecuteInLsf(ge *TaskScripts) error {
+	lsfJobID := make(map[int]string)
+
+	jobNameBase := jobNameReplace.ReplaceAllString(ge.jobName, "_")
+	resourceTemplate, err := LoadResourceTemplate("lsf")
+	if err != nil {
+		return err
+	}
+
+	for _, v := range ge.builder.Tasks {
+		if v.ShouldSkip {
+			fmt.Printf("skipping: %s\n", v.ShellScript)
+			continue
+		}
+
+		if v.CommandConfiguration.RunImmediate {
+			err := executeImmediateTask(ge, v)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		scriptInfo := ge.scripts[v.ID]
+		bsub := []string{"-J", "sf-" + jobNameBase + "__ID-" + strconv.Itoa(v.ID), "-o", path.Join(scriptInfo.JobRoot, "run.stdout"), "-e", path.Join(scriptInfo.JobRoot, "run.stderr")}
+
+		dependency := make([]string, 0)
+		for _, d := range v.DependentTaskID {
+			if u, ok := lsfJobID[d]; ok && u != "" {
+				dependency = append(dependency, "done("+u+")")
+			}
+		}
+
+		if len(dependency) > 0 {
+			bsub = append(bsub, "-w", strings.Join(dependency, " && "))
+		}
+
+		if v.CommandConfiguration.Retry > 0 {
+			// -r requeues a job if its execution host or LSF fails. A job
+			// which exits with an error is not rerun.
+			bsub = append(bsub, "-r")
+		}
+
+		resourceOptions, err := resourceTemplate.Options(&v.CommandConfiguration)
+		if err != nil {
+			return err
+		}
+		bsub = append(bsub, resourceOptions...)
+
+		if len(v.CommandConfiguration.LSFOption) > 0 {
+			bsub = append(bsub, v.CommandConfiguration.LSFOption...)
+		}
+
+		bsub = append(bsub, "/bin/bash", scriptInfo.RunScriptPath)
+
+		// write LSF options
+		submitArgs, err := os.OpenFile(path.Join(scriptInfo.JobRoot, "lsf-submit-args.txt"), os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("cannot write LSF option log: %s", err.Error())
+		}
+		defer submitArgs.Close()
+		for _, v := range bsub {
+			submitArgs.WriteString(v)
+			submitArgs.WriteString("\n")
+		}
+
+		// run LSF
+		cmd := exec.Command("bsub", bsub...)
+
+		out, err := cmd.Output()
+		if err != nil {
+			return fmt.Errorf("cannot run bsub successfully: %s", err.Error())
+		}
+		// bsub prints "Job <jobid> is submitted to queue <queue>."
+		match := bsubJobIDRegexp.FindStringSubmatch(string(out))
+		if match == nil {
+			return fmt.Errorf("cannot read job ID from bsub output: %s", out)
+		}
+		currentJobID := match[1]
+		lsfJobID[v.ID] = currentJobID
+
+		jobid, err := os.OpenFile(path.Join(scriptInfo.JobRoot, lsfJobIDFileName), os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("Cannot open LSF job ID log file: %s", err.Error())
+		}
+		defer jobid.Close()
+		fmt.Fprintf(jobid, "%s\n", currentJobID)
+		fmt.Printf("Submit ID:%s  : %s\n", currentJobID, v.ShellScript)
+	}
+
+	return nil
+}
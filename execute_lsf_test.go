@@ -0,0 +1,142 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+const fakeBsub = `#!/bin/bash
+FAKE_DIR="$(dirname "$0")"
+ID=$(( $(cat "$FAKE_DIR/counter" 2>/dev/null || echo 100) + 1 ))
+echo $ID > "$FAKE_DIR/counter"
+echo "$@" >> "$FAKE_DIR/bsub.log"
+/bin/bash "${@: -1}" > /dev/null 2>&1
+echo "Job <$ID> is submitted to default queue <normal>."
+`
+
+const fakeBjobs = `#!/bin/bash
+echo "JOBID   USER    STAT  QUEUE      FROM_HOST   EXEC_HOST   JOB_NAME   SUBMIT_TIME"
+case "${@: -1}" in
+    999)
+        echo "999     user    RUN   normal     host1       host2       sf-test    Jan  1 00:00"
+        ;;
+    998)
+        echo "998     user    EXIT  normal     host1       host2       sf-test    Jan  1 00:00"
+        ;;
+    *)
+        echo "Job <${@: -1}> is not found" 1>&2
+        exit 255
+        ;;
+esac
+`
+
+func TestExecuteInLsf(t *testing.T) {
+	ClearCache()
+	fakeDir, cleanup := setupFakeCommands(t, map[string]string{"bsub": fakeBsub, "bjobs": fakeBjobs})
+	defer cleanup()
+
+	tmp, err := NewTempDir("lsf")
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	os.Args[0] = path.Join(tmp.originalCwd, "shellflow")
+	defer tmp.Close()
+
+	testScript := `echo 1 > [[a]]
+echo 2 > [[b]]
+cat ((a)) ((b)) > [[c]]
+`
+	env := NewEnvironment()
+	builder, err := ParseShellflow(strings.NewReader(testScript), env, make(map[string]interface{}))
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	gen, err := GenerateTaskScripts("lsf.sf", "", env, builder)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	err = ExecuteInLsf(gen)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	bsubLog, err := ioutil.ReadFile(path.Join(fakeDir, "bsub.log"))
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	lines := strings.Split(strings.TrimSpace(string(bsubLog)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("bad bsub log: %s", bsubLog)
+	}
+	if !strings.HasPrefix(lines[0], "-J sf-lsf.sf__ID-1 ") || strings.Contains(lines[0], " -w ") {
+		t.Fatalf("bad bsub argument: %s", lines[0])
+	}
+	if !strings.Contains(lines[2], " -w done(101) && done(102) ") {
+		t.Fatalf("bad bsub argument: %s", lines[2])
+	}
+
+	log, err := CollectLogsForOneWork(gen.workflowRoot)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	for _, v := range log.JobLogs {
+		if v.State() != JobDone {
+			t.Fatalf("bad job state: %s", v)
+		}
+	}
+	if log.JobLogs[2].Backend != "lsf" || log.JobLogs[2].JobID != "103" {
+		t.Fatalf("bad job ID: %s %s", log.JobLogs[2].Backend, log.JobLogs[2].JobID)
+	}
+}
+
+func TestFollowUpLsf(t *testing.T) {
+	_, cleanup := setupFakeCommands(t, map[string]string{"bjobs": fakeBjobs})
+	defer cleanup()
+
+	jobRoot, err := ioutil.TempDir("", "followup_lsf")
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	defer os.RemoveAll(jobRoot)
+
+	// no job ID
+	done, err := FollowUpLsf(jobRoot)
+	if err != nil || done {
+		t.Fatalf("bad follow up result: %v %s", done, err)
+	}
+
+	// running job
+	err = ioutil.WriteFile(path.Join(jobRoot, lsfJobIDFileName), []byte("999\n"), 0644)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	done, err = FollowUpLsf(jobRoot)
+	if err != nil || !done {
+		t.Fatalf("bad follow up result: %v %s", done, err)
+	}
+	if state, err := JobStatus(jobRoot); err != nil || state != JobRunning {
+		t.Fatalf("bad state: %s %s", state, err)
+	}
+
+	// exited job without rc
+	err = ioutil.WriteFile(path.Join(jobRoot, lsfJobIDFileName), []byte("998\n"), 0644)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	done, err = FollowUpLsf(jobRoot)
+	if err != nil || !done {
+		t.Fatalf("bad follow up result: %v %s", done, err)
+	}
+	rc, err := ioutil.ReadFile(path.Join(jobRoot, "rc"))
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	if string(rc) != "1000" {
+		t.Fatalf("bad rc: %s", rc)
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const pbsJobIDFileName = "pbs-jobid.txt"
+
+// PbsExecuter submits tasks to PBS Professional or TORQUE
+type PbsExecuter struct{}
+
+func init() {
+	RegisterExecuter("pbs", &PbsExecuter{})
+}
+
+func (e *PbsExecuter) Submit(ge *TaskScripts) error {
+	return ExecuteInPbs(ge)
+}
+
+func (e *PbsExecuter) FollowUp(jobLogRoot string) (bool, error) {
+	return FollowUpPbs(jobLogRoot)
+}
+
+func (e *PbsExecuter) JobIDFileName() string {
+	return pbsJobIDFileName
+}
+
+func (e *PbsExecuter) Cancel(jobLogRoot string) (bool, error) {
+	pbsJobID, err := readJobIDFile(jobLogRoot, pbsJobIDFileName)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	out, err := exec.Command("qdel", pbsJobID).CombinedOutput()
+	if err != nil {
+		return true, fmt.Errorf("cannot run qdel successfully: %s %s", err.Error(), strings.TrimSpace(string(out)))
+	}
+	return true, nil
+}
+
+func (e *PbsExecuter) Status(jobLogRoot string) (JobState, error) {
+	pbsJobID, err := readJobIDFile(jobLogRoot, pbsJobIDFileName)
+	if os.IsNotExist(err) {
+		return JobUnknown, nil
+	} else if err != nil {
+		return JobUnknown, err
+	}
+
+	rc, err := readReturnCode(jobLogRoot)
+	if err == nil {
+		return returnCodeToJobState(rc), nil
+	} else if !os.IsNotExist(err) {
+		return JobUnknown, err
+	}
+
+	if active, _ := pbsJobState(pbsJobID); active {
+		return JobRunning, nil
+	}
+	return JobFailed, nil
+}
+
+// pbsFinishedStates are job_state of finished jobs. "C" is used by TORQUE,
+// and "F" is used by PBS Professional.
+var pbsFinishedStates = map[string]bool{
+	"C": true,
+	"F": true,
+}
+
+// pbsJobState checks whether a job is still managed by PBS with qstat -f.
+// Exit status is returned if the job is finished and qstat reports it.
+func pbsJobState(pbsJobID string) (bool, string) {
+	// -x is required to show finished jobs in PBS Professional. TORQUE
+	// accepts -x but prints XML instead, so qstat is run again without -x
+	// if job_state is not found.
+	state, exitStatus := "", ""
+	out, err := exec.Command("qstat", "-f", "-x", pbsJobID).Output()
+	if err == nil {
+		state, exitStatus = parsePbsQstat(string(out))
+	}
+	if state == "" {
+		out, err = exec.Command("qstat", "-f", pbsJobID).Output()
+		if err != nil {
+			return false, ""
+		}
+		state, exitStatus = parsePbsQstat(string(out))
+	}
+
+	if state == "" || pbsFinishedStates[state] {
+		return false, exitStatus
+	}
+	return true, ""
+}
+
+// parsePbsQstat reads job_state and exit_status in output of qstat -f
+func parsePbsQstat(output string) (string, string) {
+	state := ""
+	exitStatus := ""
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.SplitN(line, "=", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(fields[0])) {
+		case "job_state":
+			state = strings.TrimSpace(fields[1])
+		case "exit_status":
+			exitStatus = strings.TrimSpace(fields[1])
+		}
+	}
+	return state, exitStatus
+}
+
+func FollowUpPbs(jobLogRoot string) (bool, error) {
+	_, err := readReturnCode(jobLogRoot)
+	if err == nil {
+		return false, nil
+	} else if !os.IsNotExist(err) {
+		return false, err
+	}
+
+	pbsJobID, err := readJobIDFile(jobLogRoot, pbsJobIDFileName)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	active, exitStatus := pbsJobState(pbsJobID)
+	if !active {
+		reason := "PBS job is vanished"
+		if exitStatus != "" {
+			reason = fmt.Sprintf("PBS job is finished with exit status %s without return code", exitStatus)
+		}
+		if err := writeJobResult(jobLogRoot, 1000, reason); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func ExecuteInPbs(ge *TaskScripts) error {
+	pbsJobID := make(map[int]string)
+
+	jobNameBase := jobNameReplace.ReplaceAllString(ge.jobName, "_")
+	resourceTemplate, err := LoadResourceTemplate("pbs")
+	if err != nil {
+		return err
+	}
+
+	for _, v := range ge.builder.Tasks {
+		if v.ShouldSkip {
+			fmt.Printf("skipping: %s\n", v.ShellScript)
+			continue
+		}
+
+		if v.CommandConfiguration.RunImmediate {
+			err := executeImmediateTask(ge, v)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		scriptInfo := ge.scripts[v.ID]
+		qsub := []string{"-o", path.Join(scriptInfo.JobRoot, "run.stdout"), "-e", path.Join(scriptInfo.JobRoot, "run.stderr")}
+
+		dependency := make([]string, 0)
+		for _, d := range v.DependentTaskID {
+			if u, ok := pbsJobID[d]; ok && u != "" {
+				dependency = append(dependency, u)
+			}
+		}
+
+		if len(dependency) > 0 {
+			qsub = append(qsub, "-W", "depend=afterok:"+strings.Join(dependency, ":"))
+		}
+
+		qsub = append(qsub, "-N", "sf-"+jobNameBase+"__ID-"+strconv.Itoa(v.ID))
+
+		if v.CommandConfiguration.Retry > 0 {
+			// -r y marks a job rerunnable, so the server may requeue it when
+			// its execution host goes down or an administrator runs qrerun.
+			qsub = append(qsub, "-r", "y")
+		}
+
+		resourceOptions, err := resourceTemplate.Options(&v.CommandConfiguration)
+		if err != nil {
+			return err
+		}
+		qsub = append(qsub, resourceOptions...)
+
+		if len(v.CommandConfiguration.PBSOption) > 0 {
+			qsub = append(qsub, v.CommandConfiguration.PBSOption...)
+		}
+
+		qsub = append(qsub, scriptInfo.RunScriptPath)
+
+		// write PBS options
+		submitArgs, err := os.OpenFile(path.Join(scriptInfo.JobRoot, "pbs-submit-args.txt"), os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("cannot write PBS option log: %s", err.Error())
+		}
+		defer submitArgs.Close()
+		for _, v := range qsub {
+			submitArgs.WriteString(v)
+			submitArgs.WriteString("\n")
+		}
+
+		// run PBS
+		cmd := exec.Command("qsub", qsub...)
+
+		out, err := cmd.Output()
+		if err != nil {
+			return fmt.Errorf("cannot run qsub successfully: %s", err.Error())
+		}
+		currentJobID := strings.TrimSpace(string(out))
+		if currentJobID == "" {
+			return fmt.Errorf("cannot read job ID from qsub output: %s", out)
+		}
+		pbsJobID[v.ID] = currentJobID
+
+		jobid, err := os.OpenFile(path.Join(scriptInfo.JobRoot, pbsJobIDFileName), os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("Cannot open PBS job ID log file: %s", err.Error())
+		}
+		defer jobid.Close()
+		fmt.Fprintf(jobid, "%s\n", currentJobID)
+		fmt.Printf("Submit ID:%s  : %s\n", currentJobID, v.ShellScript)
+	}
+
+	return nil
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+const fakePbsQsub = `#!/bin/bash
+FAKE_DIR="$(dirname "$0")"
+ID=$(( $(cat "$FAKE_DIR/counter" 2>/dev/null || echo 100) + 1 ))
+echo $ID > "$FAKE_DIR/counter"
+echo "$@" >> "$FAKE_DIR/qsub.log"
+/bin/bash "${@: -1}" > /dev/null 2>&1
+echo "$ID.server"
+`
+
+// 999.server is a running job in TORQUE, which prints XML with -x, and
+// 998.server is a finished job in PBS Professional, which shows finished
+// jobs only with -x.
+const fakePbsQstat = `#!/bin/bash
+case "${@: -1}" in
+    999.server)
+        if [ "$2" = "-x" ]; then
+            echo "<Data><Job><Job_Id>999.server</Job_Id><job_state>R</job_state></Job></Data>"
+        else
+            echo "Job Id: 999.server"
+            echo "    job_state = R"
+        fi
+        ;;
+    998.server)
+        if [ "$2" != "-x" ]; then
+            echo "qstat: 998.server Job has finished, use -x or -H to obtain historical job information" 1>&2
+            exit 1
+        fi
+        echo "Job Id: 998.server"
+        echo "    job_state = F"
+        echo "    exit_status = 137"
+        ;;
+    *)
+        echo "qstat: Unknown Job Id ${@: -1}" 1>&2
+        exit 1
+        ;;
+esac
+`
+
+func TestExecuteInPbs(t *testing.T) {
+	ClearCache()
+	fakeDir, cleanup := setupFakeCommands(t, map[string]string{"qsub": fakePbsQsub, "qstat": fakePbsQstat})
+	defer cleanup()
+
+	tmp, err := NewTempDir("pbs")
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	os.Args[0] = path.Join(tmp.originalCwd, "shellflow")
+	defer tmp.Close()
+
+	testScript := `echo 1 > [[a]]
+cat ((a)) > [[b]]
+`
+	env := NewEnvironment()
+	builder, err := ParseShellflow(strings.NewReader(testScript), env, make(map[string]interface{}))
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	gen, err := GenerateTaskScripts("pbs.sf", "", env, builder)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	err = ExecuteInPbs(gen)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	qsubLog, err := ioutil.ReadFile(path.Join(fakeDir, "qsub.log"))
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	lines := strings.Split(strings.TrimSpace(string(qsubLog)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("bad qsub log: %s", qsubLog)
+	}
+	if !strings.HasPrefix(lines[0], "-o ") || strings.Contains(lines[0], "depend=") {
+		t.Fatalf("bad qsub argument: %s", lines[0])
+	}
+	if !strings.Contains(lines[1], "-W depend=afterok:101.server ") {
+		t.Fatalf("bad qsub argument: %s", lines[1])
+	}
+
+	log, err := CollectLogsForOneWork(gen.workflowRoot)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	for _, v := range log.JobLogs {
+		if v.State() != JobDone {
+			t.Fatalf("bad job state: %s", v)
+		}
+	}
+	if log.JobLogs[1].Backend != "pbs" || log.JobLogs[1].JobID != "102.server" {
+		t.Fatalf("bad job ID: %s %s", log.JobLogs[1].Backend, log.JobLogs[1].JobID)
+	}
+}
+
+func TestFollowUpPbs(t *testing.T) {
+	_, cleanup := setupFakeCommands(t, map[string]string{"qstat": fakePbsQstat})
+	defer cleanup()
+
+	jobRoot, err := ioutil.TempDir("", "followup_pbs")
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	defer os.RemoveAll(jobRoot)
+
+	// no job ID
+	done, err := FollowUpPbs(jobRoot)
+	if err != nil || done {
+		t.Fatalf("bad follow up result: %v %s", done, err)
+	}
+
+	// running job in TORQUE
+	err = ioutil.WriteFile(path.Join(jobRoot, pbsJobIDFileName), []byte("999.server\n"), 0644)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	done, err = FollowUpPbs(jobRoot)
+	if err != nil || !done {
+		t.Fatalf("bad follow up result: %v %s", done, err)
+	}
+	if _, err := os.Stat(path.Join(jobRoot, "rc")); !os.IsNotExist(err) {
+		t.Fatalf("rc should not be created: %s", err)
+	}
+
+	// finished job in PBS Professional without rc
+	err = ioutil.WriteFile(path.Join(jobRoot, pbsJobIDFileName), []byte("998.server\n"), 0644)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	done, err = FollowUpPbs(jobRoot)
+	if err != nil || !done {
+		t.Fatalf("bad follow up result: %v %s", done, err)
+	}
+	rc, err := ioutil.ReadFile(path.Join(jobRoot, "rc"))
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	if string(rc) != "1000" {
+		t.Fatalf("bad rc: %s", rc)
+	}
+	reason, err := ioutil.ReadFile(path.Join(jobRoot, jobReasonFileName))
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	if !strings.Contains(string(reason), "exit status 137") {
+		t.Fatalf("bad reason: %s", reason)
+	}
+
+	// vanished job
+	os.Remove(path.Join(jobRoot, "rc"))
+	err = ioutil.WriteFile(path.Join(jobRoot, pbsJobIDFileName), []byte("123.server\n"), 0644)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	done, err = FollowUpPbs(jobRoot)
+	if err != nil || !done {
+		t.Fatalf("bad follow up result: %v %s", done, err)
+	}
+	if state, err := JobStatus(jobRoot); err != nil || state != JobFailed {
+		t.Fatalf("bad state: %s %s", state, err)
+	}
+}
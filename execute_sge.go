@@ -12,6 +12,127 @@ import (
 
 const sgeTaskIDFileName = "sge-taskid.txt"
 
+// SgeExecuter submits tasks to Sun/Univa Grid Engine
+type SgeExecuter struct{}
+
+func init() {
+	RegisterExecuter("sge", &SgeExecuter{})
+}
+
+func (e *SgeExecuter) Submit(ge *TaskScripts) error {
+	return ExecuteInSge(ge)
+}
+
+func (e *SgeExecuter) FollowUp(jobLogRoot string) (bool, error) {
+	return FollowUpSge(jobLogRoot)
+}
+
+func (e *SgeExecuter) JobIDFileName() string {
+	return sgeTaskIDFileName
+}
+
+func (e *SgeExecuter) Cancel(jobLogRoot string) (bool, error) {
+	sgeTaskID, err := readJobIDFile(jobLogRoot, sgeTaskIDFileName)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	out, err := exec.Command("qdel", sgeTaskID).CombinedOutput()
+	if err != nil {
+		return true, fmt.Errorf("cannot run qdel successfully: %s %s", err.Error(), strings.TrimSpace(string(out)))
+	}
+	return true, nil
+}
+
+func (e *SgeExecuter) Status(jobLogRoot string) (JobState, error) {
+	sgeTaskID, err := readJobIDFile(jobLogRoot, sgeTaskIDFileName)
+	if os.IsNotExist(err) {
+		return JobUnknown, nil
+	} else if err != nil {
+		return JobUnknown, err
+	}
+
+	rc, err := readReturnCode(jobLogRoot)
+	if err == nil {
+		return returnCodeToJobState(rc), nil
+	} else if !os.IsNotExist(err) {
+		return JobUnknown, err
+	}
+
+	if exec.Command("qstat", "-j", sgeTaskID).Run() == nil {
+		return JobRunning, nil
+	}
+	return JobFailed, nil
+}
+
+// CollectResourceUsage reads resource usage of a finished job from qacct.
+// nil is returned if the job is not finished or accounting data is not available yet.
+func (e *SgeExecuter) CollectResourceUsage(jobLogRoot string) (*ResourceUsage, error) {
+	sgeTaskID, err := readJobIDFile(jobLogRoot, sgeTaskIDFileName)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if _, err := readReturnCode(jobLogRoot); err != nil {
+		return nil, nil
+	}
+
+	out, err := exec.Command("qacct", "-j", sgeTaskID).Output()
+	if err != nil {
+		return nil, nil
+	}
+	return parseQacct(string(out))
+}
+
+func parseQacct(output string) (*ResourceUsage, error) {
+	var usage ResourceUsage
+	found := false
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+
+		var err error
+		switch fields[0] {
+		case "ru_wallclock":
+			usage.WallTime, err = parseQacctSeconds(fields[1])
+		case "ru_utime":
+			usage.UserTime, err = parseQacctSeconds(fields[1])
+		case "ru_stime":
+			usage.SystemTime, err = parseQacctSeconds(fields[1])
+		case "ru_maxrss":
+			// ru_maxrss is kilobytes when no unit is appended
+			value := strings.TrimSuffix(fields[1], "B")
+			if _, e := strconv.ParseFloat(value, 64); e == nil {
+				value += "K"
+			}
+			usage.MaxRSS, err = NewMemory(value)
+		case "maxvmem":
+			usage.MaxVMem, err = NewMemory(strings.TrimSuffix(fields[1], "B"))
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Cannot parse qacct output: %s: %s", line, err.Error())
+		}
+		found = true
+	}
+
+	if !found {
+		return nil, nil
+	}
+	return &usage, nil
+}
+
+func parseQacctSeconds(value string) (float64, error) {
+	return strconv.ParseFloat(strings.TrimSuffix(value, "s"), 64)
+}
+
 func FollowUpSge(jobLogRoot string) (bool, error) {
 	rc, err := os.Open(path.Join(jobLogRoot, "rc"))
 	if err == nil {
@@ -60,6 +181,10 @@ func ExecuteInSge(ge *TaskScripts) error {
 	sgeTaskID := make(map[int]string)
 
 	jobNameBase := jobNameReplace.ReplaceAllString(ge.jobName, "_")
+	resourceTemplate, err := LoadResourceTemplate("sge")
+	if err != nil {
+		return err
+	}
 
 	for _, v := range ge.builder.Tasks {
 		if v.ShouldSkip {
@@ -68,7 +193,7 @@ func ExecuteInSge(ge *TaskScripts) error {
 		}
 
 		if v.CommandConfiguration.RunImmediate {
-			err := ExecuteLocalSingleOneTask(ge, v)
+			err := executeImmediateTask(ge, v)
 			if err != nil {
 				return err
 			}
@@ -97,6 +222,17 @@ func ExecuteInSge(ge *TaskScripts) error {
 
 		qsub = append(qsub, "-N", "sf-"+jobNameBase+"__ID-"+strconv.Itoa(v.ID))
 
+		if v.CommandConfiguration.Retry > 0 {
+			// rerun a job when an execution host is crashed
+			qsub = append(qsub, "-r", "y")
+		}
+
+		resourceOptions, err := resourceTemplate.Options(&v.CommandConfiguration)
+		if err != nil {
+			return err
+		}
+		qsub = append(qsub, resourceOptions...)
+
 		if len(v.CommandConfiguration.SGEOption) > 0 {
 			qsub = append(qsub, v.CommandConfiguration.SGEOption...)
 		}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestParseQacct(t *testing.T) {
+	usage, err := parseQacct(`==============================================================
+qname        all.q
+hostname     node1
+jobnumber    123
+exit_status  0
+ru_wallclock 62s
+ru_utime     10.500s
+ru_stime     1.250s
+ru_maxrss    2048
+maxvmem      1.500G
+`)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	if usage.WallTime != 62 || usage.UserTime != 10.5 || usage.SystemTime != 1.25 {
+		t.Fatalf("bad resource usage: %s", usage)
+	}
+	if usage.MaxRSS.Byte() != 2048*1024 || usage.MaxVMem.Byte() != 1536*1024*1024 {
+		t.Fatalf("bad resource usage: %s %s", usage.MaxRSS, usage.MaxVMem)
+	}
+
+	usage, err = parseQacct("error: job id 123 not found\n")
+	if err != nil || usage != nil {
+		t.Fatalf("bad result: %s %s", usage, err)
+	}
+}
+
+func TestCollectResourceUsage(t *testing.T) {
+	// accounting data of job 789 is available at second query
+	fakeDir, cleanup := setupFakeCommands(t, map[string]string{"qacct": `#!/bin/bash
+FAKE_DIR="$(dirname "$0")"
+echo $2 >> "$FAKE_DIR/qacct.log"
+if [ "$2" = 789 ] && [ ! -e "$FAKE_DIR/qacct-789" ]; then
+    touch "$FAKE_DIR/qacct-789"
+elif [ "$2" = 123 ] || [ "$2" = 789 ]; then
+    printf "ru_wallclock 62s\nru_utime 10.500s\n"
+    exit 0
+fi
+echo "error: job id $2 not found" >&2
+exit 1
+`})
+	defer cleanup()
+
+	jobRoot, err := ioutil.TempDir("", "resource")
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	defer os.RemoveAll(jobRoot)
+
+	for _, v := range []struct {
+		jobID     string
+		finished  time.Time
+		available []bool
+	}{
+		{"123", time.Now(), []bool{true, true}},
+		{"789", time.Now(), []bool{false, true}},
+		{"456", time.Now().Add(-2 * resourceUsageRetryPeriod), []bool{false, false}},
+	} {
+		jobLogRoot := path.Join(jobRoot, v.jobID)
+		err = os.MkdirAll(jobLogRoot, 0755)
+		if err != nil {
+			t.Fatalf("error: %s", err.Error())
+		}
+		err = ioutil.WriteFile(path.Join(jobLogRoot, sgeTaskIDFileName), []byte(v.jobID+"\n"), 0644)
+		if err != nil {
+			t.Fatalf("error: %s", err.Error())
+		}
+		err = ioutil.WriteFile(path.Join(jobLogRoot, "rc"), []byte("0"), 0644)
+		if err != nil {
+			t.Fatalf("error: %s", err.Error())
+		}
+		err = os.Chtimes(path.Join(jobLogRoot, "rc"), v.finished, v.finished)
+		if err != nil {
+			t.Fatalf("error: %s", err.Error())
+		}
+
+		for i, available := range v.available {
+			usage, err := CollectResourceUsage(jobLogRoot)
+			if err != nil {
+				t.Fatalf("error: %s", err.Error())
+			}
+			if available && (usage == nil || usage.WallTime != 62 || usage.UserTime != 10.5) {
+				t.Fatalf("bad resource usage of %s at %d: %s", v.jobID, i, usage)
+			}
+			if !available && usage != nil {
+				t.Fatalf("resource usage of %s should not be available at %d: %s", v.jobID, i, usage)
+			}
+		}
+	}
+
+	// marker file is written only for a job finished before retry period
+	for k, v := range map[string]bool{"123": false, "789": false, "456": true} {
+		_, err := os.Stat(path.Join(jobRoot, k, resourceUsageUnavailableFileName))
+		if v != (err == nil) {
+			t.Fatalf("bad marker file of %s: %v", k, err)
+		}
+	}
+	qacctLog, err := ioutil.ReadFile(path.Join(fakeDir, "qacct.log"))
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	if string(qacctLog) != "123\n789\n789\n456\n" {
+		t.Fatalf("bad qacct calls: %s", qacctLog)
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const slurmJobIDFileName = "slurm-jobid.txt"
+
+// SlurmExecuter submits tasks to Slurm
+type SlurmExecuter struct{}
+
+func init() {
+	RegisterExecuter("slurm", &SlurmExecuter{})
+}
+
+func (e *SlurmExecuter) Submit(ge *TaskScripts) error {
+	return ExecuteInSlurm(ge)
+}
+
+func (e *SlurmExecuter) FollowUp(jobLogRoot string) (bool, error) {
+	return FollowUpSlurm(jobLogRoot)
+}
+
+func (e *SlurmExecuter) JobIDFileName() string {
+	return slurmJobIDFileName
+}
+
+func (e *SlurmExecuter) Cancel(jobLogRoot string) (bool, error) {
+	slurmJobID, err := readJobIDFile(jobLogRoot, slurmJobIDFileName)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	out, err := exec.Command("scancel", slurmJobID).CombinedOutput()
+	if err != nil {
+		return true, fmt.Errorf("cannot run scancel successfully: %s %s", err.Error(), strings.TrimSpace(string(out)))
+	}
+	return true, nil
+}
+
+func (e *SlurmExecuter) Status(jobLogRoot string) (JobState, error) {
+	slurmJobID, err := readJobIDFile(jobLogRoot, slurmJobIDFileName)
+	if os.IsNotExist(err) {
+		return JobUnknown, nil
+	} else if err != nil {
+		return JobUnknown, err
+	}
+
+	rc, err := readReturnCode(jobLogRoot)
+	if err == nil {
+		return returnCodeToJobState(rc), nil
+	} else if !os.IsNotExist(err) {
+		return JobUnknown, err
+	}
+
+	if isSlurmJobActive(slurmJobID) {
+		return JobRunning, nil
+	}
+	return JobFailed, nil
+}
+
+var slurmActiveStates = map[string]bool{
+	"PENDING":      true,
+	"RUNNING":      true,
+	"REQUEUED":     true,
+	"RESIZING":     true,
+	"SUSPENDED":    true,
+	"CONFIGURING":  true,
+	"COMPLETING":   true,
+	"REQUEUE_HOLD": true,
+	"REQUEUE_FED":  true,
+}
+
+// isSlurmJobActive checks whether a job is still managed by Slurm.
+// squeue is used at first, and sacct is used when the job is not found in the queue.
+func isSlurmJobActive(slurmJobID string) bool {
+	out, err := exec.Command("squeue", "-h", "-j", slurmJobID, "-o", "%T").Output()
+	if err == nil && strings.TrimSpace(string(out)) != "" {
+		return true
+	}
+
+	out, err = exec.Command("sacct", "-n", "-X", "-P", "-j", slurmJobID, "-o", "State").Output()
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 0 && slurmActiveStates[fields[0]] {
+			return true
+		}
+	}
+	return false
+}
+
+func FollowUpSlurm(jobLogRoot string) (bool, error) {
+	rc, err := os.Open(path.Join(jobLogRoot, "rc"))
+	if err == nil {
+		defer rc.Close()
+		return false, nil
+	} else if os.IsNotExist(err) {
+		slurmJobID, err := readJobIDFile(jobLogRoot, slurmJobIDFileName)
+		if err == nil {
+			if !isSlurmJobActive(slurmJobID) {
+				rc, err = os.OpenFile(path.Join(jobLogRoot, "rc"), os.O_CREATE|os.O_WRONLY, 0644)
+				if err != nil {
+					return false, err
+				}
+				defer rc.Close()
+				_, err = fmt.Fprintf(rc, "1000")
+				if err != nil {
+					return false, err
+				}
+			}
+			return true, nil
+		} else if os.IsNotExist(err) {
+			return false, nil
+		} else {
+			return false, err
+		}
+	} else {
+		return false, err
+	}
+}
+
+func ExecuteInSlurm(ge *TaskScripts) error {
+	slurmJobID := make(map[int]string)
+
+	jobNameBase := jobNameReplace.ReplaceAllString(ge.jobName, "_")
+	resourceTemplate, err := LoadResourceTemplate("slurm")
+	if err != nil {
+		return err
+	}
+
+	for _, v := range ge.builder.Tasks {
+		if v.ShouldSkip {
+			fmt.Printf("skipping: %s\n", v.ShellScript)
+			continue
+		}
+
+		if v.CommandConfiguration.RunImmediate {
+			err := executeImmediateTask(ge, v)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		scriptInfo := ge.scripts[v.ID]
+		sbatch := []string{"--parsable", "-D", scriptInfo.JobRoot, "-o", path.Join(scriptInfo.JobRoot, "run.stdout"), "-e", path.Join(scriptInfo.JobRoot, "run.stderr")}
+
+		dependency := make([]string, 0)
+		for _, d := range v.DependentTaskID {
+			if u, ok := slurmJobID[d]; ok && u != "" {
+				dependency = append(dependency, u)
+			}
+		}
+
+		if len(dependency) > 0 {
+			sbatch = append(sbatch, "--dependency=afterok:"+strings.Join(dependency, ":"))
+		}
+
+		sbatch = append(sbatch, "-J", "sf-"+jobNameBase+"__ID-"+strconv.Itoa(v.ID))
+
+		if v.CommandConfiguration.Retry > 0 {
+			// requeue a job when a node is failed
+			sbatch = append(sbatch, "--requeue")
+		}
+
+		resourceOptions, err := resourceTemplate.Options(&v.CommandConfiguration)
+		if err != nil {
+			return err
+		}
+		sbatch = append(sbatch, resourceOptions...)
+
+		if len(v.CommandConfiguration.SlurmOption) > 0 {
+			sbatch = append(sbatch, v.CommandConfiguration.SlurmOption...)
+		}
+
+		sbatch = append(sbatch, scriptInfo.RunScriptPath)
+
+		// write Slurm options
+		submitArgs, err := os.OpenFile(path.Join(scriptInfo.JobRoot, "slurm-submit-args.txt"), os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("cannot write Slurm option log: %s", err.Error())
+		}
+		defer submitArgs.Close()
+		for _, v := range sbatch {
+			submitArgs.WriteString(v)
+			submitArgs.WriteString("\n")
+		}
+
+		// run Slurm
+		cmd := exec.Command("sbatch", sbatch...)
+
+		out, err := cmd.Output()
+		if err != nil {
+			return fmt.Errorf("cannot run sbatch successfully: %s", err.Error())
+		}
+		// sbatch --parsable prints "jobid" or "jobid;cluster"
+		currentJobID := strings.SplitN(strings.TrimSpace(string(out)), ";", 2)[0]
+		if currentJobID == "" {
+			return fmt.Errorf("cannot read job ID from sbatch output: %s", out)
+		}
+		slurmJobID[v.ID] = currentJobID
+
+		jobid, err := os.OpenFile(path.Join(scriptInfo.JobRoot, slurmJobIDFileName), os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("Cannot open Slurm job ID log file: %s", err.Error())
+		}
+		defer jobid.Close()
+		fmt.Fprintf(jobid, "%s\n", currentJobID)
+		fmt.Printf("Submit ID:%s  : %s\n", currentJobID, v.ShellScript)
+	}
+
+	return nil
+}
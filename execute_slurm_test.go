@@ -0,0 +1,168 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+const fakeSbatch = `#!/bin/bash
+FAKE_DIR="$(dirname "$0")"
+ID=$(( $(cat "$FAKE_DIR/counter" 2>/dev/null || echo 100) + 1 ))
+echo $ID > "$FAKE_DIR/counter"
+echo "$@" >> "$FAKE_DIR/sbatch.log"
+/bin/bash "${@: -1}" > /dev/null 2>&1
+echo "$ID;cluster"
+`
+
+const fakeSqueue = `#!/bin/bash
+if [ "$3" = "999" ]; then
+    echo RUNNING
+fi
+`
+
+const fakeSacct = `#!/bin/bash
+echo FAILED
+`
+
+func setupFakeCommands(t *testing.T, commands map[string]string) (string, func()) {
+	fakeDir, err := ioutil.TempDir("", "fakebin")
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	for k, v := range commands {
+		err = ioutil.WriteFile(path.Join(fakeDir, k), []byte(v), 0755)
+		if err != nil {
+			t.Fatalf("error: %s", err.Error())
+		}
+	}
+	originalPath := os.Getenv("PATH")
+	os.Setenv("PATH", fakeDir+":"+originalPath)
+	return fakeDir, func() {
+		os.Setenv("PATH", originalPath)
+		os.RemoveAll(fakeDir)
+	}
+}
+
+func TestExecuteInSlurm(t *testing.T) {
+	ClearCache()
+	fakeDir, cleanup := setupFakeCommands(t, map[string]string{"sbatch": fakeSbatch, "squeue": fakeSqueue, "sacct": fakeSacct})
+	defer cleanup()
+
+	tmp, err := NewTempDir("slurm")
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	os.Args[0] = path.Join(tmp.originalCwd, "shellflow")
+	defer tmp.Close()
+
+	testScript := `echo 1 > [[a]]
+cat ((a)) > [[b]]
+`
+	env := NewEnvironment()
+	builder, err := ParseShellflow(strings.NewReader(testScript), env, make(map[string]interface{}))
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	builder.Tasks[1].CommandConfiguration.CPU = 2
+	builder.Tasks[1].CommandConfiguration.Memory = "4G"
+
+	gen, err := GenerateTaskScripts("slurm.sf", "", env, builder)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	err = ExecuteInSlurm(gen)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	sbatchLog, err := ioutil.ReadFile(path.Join(fakeDir, "sbatch.log"))
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	lines := strings.Split(strings.TrimSpace(string(sbatchLog)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("bad sbatch log: %s", sbatchLog)
+	}
+	if !strings.HasPrefix(lines[0], "--parsable") || strings.Contains(lines[0], "--dependency") {
+		t.Fatalf("bad sbatch argument: %s", lines[0])
+	}
+	if !strings.Contains(lines[1], "--dependency=afterok:101 ") {
+		t.Fatalf("bad sbatch argument: %s", lines[1])
+	}
+	if strings.Contains(lines[0], "--mem") || !strings.Contains(lines[1], " --mem=4096M --cpus-per-task=2 ") {
+		t.Fatalf("bad sbatch resource argument: %s", lines[1])
+	}
+
+	jobID, err := ioutil.ReadFile(path.Join(gen.scripts[2].JobRoot, slurmJobIDFileName))
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	if string(jobID) != "102\n" {
+		t.Fatalf("bad job ID: %s", jobID)
+	}
+
+	log, err := CollectLogsForOneWork(gen.workflowRoot)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	for _, v := range log.JobLogs {
+		if v.State() != JobDone {
+			t.Fatalf("bad job state: %s", v)
+		}
+	}
+	if log.JobLogs[1].Backend != "slurm" || log.JobLogs[1].JobID != "102" {
+		t.Fatalf("bad job ID: %s %s", log.JobLogs[1].Backend, log.JobLogs[1].JobID)
+	}
+}
+
+func TestFollowUpSlurm(t *testing.T) {
+	_, cleanup := setupFakeCommands(t, map[string]string{"squeue": fakeSqueue, "sacct": fakeSacct})
+	defer cleanup()
+
+	jobRoot, err := ioutil.TempDir("", "followup_slurm")
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	defer os.RemoveAll(jobRoot)
+
+	// no job ID
+	done, err := FollowUpSlurm(jobRoot)
+	if err != nil || done {
+		t.Fatalf("bad follow up result: %v %s", done, err)
+	}
+
+	// running job
+	err = ioutil.WriteFile(path.Join(jobRoot, slurmJobIDFileName), []byte("999\n"), 0644)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	done, err = FollowUpSlurm(jobRoot)
+	if err != nil || !done {
+		t.Fatalf("bad follow up result: %v %s", done, err)
+	}
+	if _, err := os.Stat(path.Join(jobRoot, "rc")); !os.IsNotExist(err) {
+		t.Fatalf("rc should not be created: %s", err)
+	}
+
+	// vanished job
+	err = ioutil.WriteFile(path.Join(jobRoot, slurmJobIDFileName), []byte("123\n"), 0644)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	done, err = FollowUpSlurm(jobRoot)
+	if err != nil || !done {
+		t.Fatalf("bad follow up result: %v %s", done, err)
+	}
+	rc, err := ioutil.ReadFile(path.Join(jobRoot, "rc"))
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	if string(rc) != "1000" {
+		t.Fatalf("bad rc: %s", rc)
+	}
+}
@@ -4,12 +4,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"os/user"
 	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -34,8 +36,183 @@ type TaskScripts struct {
 	builder      *ShellTaskBuilder
 }
 
-type Execute func(ge *TaskScripts) error
-type FollowUp func(log *JobLog) error
+// Executer is an interface of job execution backends
+type Executer interface {
+	// Submit runs or submits tasks
+	Submit(ge *TaskScripts) error
+	// FollowUp checks a job which does not have a return code file.
+	// Return true if the job is managed by this executer.
+	FollowUp(jobLogRoot string) (bool, error)
+	// Cancel stops a job. Return true if the job is managed by this executer.
+	Cancel(jobLogRoot string) (bool, error)
+	// Status returns current state of a job.
+	// JobUnknown is returned if the job is not managed by this executer.
+	Status(jobLogRoot string) (JobState, error)
+}
+
+// DefaultExecuter is a name of executer used when no backend is configured
+const DefaultExecuter = "local"
+
+var executers = make(map[string]Executer)
+
+// RegisterExecuter registers an executer with a name used in [Backend] Type
+func RegisterExecuter(name string, executer Executer) {
+	executers[name] = executer
+}
+
+// GetExecuter returns registered executer
+func GetExecuter(name string) (Executer, error) {
+	if name == "" {
+		name = DefaultExecuter
+	}
+	executer, ok := executers[name]
+	if !ok {
+		return nil, fmt.Errorf("Unknown backend type: %s (available: %s)", name, strings.Join(ExecuterNames(), ", "))
+	}
+	return executer, nil
+}
+
+// ExecuterNames returns sorted names of registered executers
+func ExecuterNames() []string {
+	names := make([]string, 0, len(executers))
+	for k := range executers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FollowUpJob checks a job with all registered executers
+func FollowUpJob(jobLogRoot string) (bool, error) {
+	for _, name := range ExecuterNames() {
+		done, err := executers[name].FollowUp(jobLogRoot)
+		if err != nil || done {
+			return done, err
+		}
+	}
+	return false, nil
+}
+
+// CancelJob cancels a job with all registered executers
+func CancelJob(jobLogRoot string) (bool, error) {
+	for _, name := range ExecuterNames() {
+		done, err := executers[name].Cancel(jobLogRoot)
+		if err != nil || done {
+			return done, err
+		}
+	}
+	return false, nil
+}
+
+// JobStatus returns current state of a job with all registered executers
+func JobStatus(jobLogRoot string) (JobState, error) {
+	for _, name := range ExecuterNames() {
+		state, err := executers[name].Status(jobLogRoot)
+		if err != nil || state != JobUnknown {
+			return state, err
+		}
+	}
+	return JobPending, nil
+}
+
+// readReturnCode reads a return code file in a job log directory
+func readReturnCode(jobLogRoot string) (int, error) {
+	data, err := ioutil.ReadFile(path.Join(jobLogRoot, "rc"))
+	if err != nil {
+		return 0, err
+	}
+	rc, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, err
+	}
+	return rc, nil
+}
+
+// readJobIDFile reads a job ID written by a job scheduler backend
+func readJobIDFile(jobLogRoot string, name string) (string, error) {
+	data, err := ioutil.ReadFile(path.Join(jobLogRoot, name))
+	if err != nil {
+		return "", err
+	}
+	jobID := strings.TrimSpace(string(data))
+	if jobID == "" {
+		return "", fmt.Errorf("Cannot read job ID: %s", path.Join(jobLogRoot, name))
+	}
+	return jobID, nil
+}
+
+// jobIDRecorder is implemented by executers which record an ID of a job
+// given by a job scheduler in a job log directory.
+type jobIDRecorder interface {
+	JobIDFileName() string
+}
+
+// readSchedulerJobID returns a name of an executer which submitted a job and
+// an ID of the job given by a job scheduler. Empty strings are returned if
+// the job is not submitted to a job scheduler.
+func readSchedulerJobID(jobLogRoot string) (string, string, error) {
+	for _, name := range ExecuterNames() {
+		recorder, ok := executers[name].(jobIDRecorder)
+		if !ok {
+			continue
+		}
+		jobID, err := readJobIDFile(jobLogRoot, recorder.JobIDFileName())
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return "", "", err
+		}
+		return name, jobID, nil
+	}
+	return "", "", nil
+}
+
+// TimeoutExitCode is written to rc file when a job is killed by timeout
+const TimeoutExitCode = 3000
+
+// CancelledExitCode is written to rc file when a job is cancelled by user
+const CancelledExitCode = 4000
+
+// jobReasonFileName is a file to record why a job is terminated by shellflow
+const jobReasonFileName = "reason.txt"
+
+func returnCodeToJobState(rc int) JobState {
+	if rc == 0 {
+		return JobDone
+	} else if rc == TimeoutExitCode {
+		return JobTimeout
+	} else if rc == CancelledExitCode {
+		return JobCancelled
+	}
+	return JobFailed
+}
+
+// writeJobResult writes return code and reason of a job which is terminated by shellflow
+func writeJobResult(jobLogRoot string, rc int, reason string) error {
+	err := writeJobResultReason(jobLogRoot, reason)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path.Join(jobLogRoot, "rc"), []byte(strconv.Itoa(rc)), 0644)
+}
+
+func writeJobResultReason(jobLogRoot string, reason string) error {
+	return ioutil.WriteFile(path.Join(jobLogRoot, jobReasonFileName), []byte(reason+"\n"), 0644)
+}
+
+// writeReturnCodeIfNotExist writes return code unless a job already has rc file.
+// Return false if rc file already exists.
+func writeReturnCodeIfNotExist(jobLogRoot string, rc int) (bool, error) {
+	rcFile, err := os.OpenFile(path.Join(jobLogRoot, "rc"), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if os.IsExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	defer rcFile.Close()
+	_, err = fmt.Fprintf(rcFile, "%d", rc)
+	return true, err
+}
 
 type WorkflowMetaData struct {
 	Env           map[string]string
@@ -179,6 +356,13 @@ func GenerateTaskScripts(scriptPath string, paramPath string, env *Environment,
 				return nil, err
 			}
 
+			if fingerprint := builder.Fingerprint(v.ID); fingerprint != nil {
+				err = writeFingerprint(jobDir, fingerprint)
+				if err != nil {
+					return nil, err
+				}
+			}
+
 			absScriptPath := Abs(path.Join(jobDir, "script.sh"))
 			absRunScriptPath := Abs(path.Join(jobDir, "run.sh"))
 			absStdoutPath := Abs(path.Join(jobDir, "script.stdout"))
@@ -188,10 +372,14 @@ func GenerateTaskScripts(scriptPath string, paramPath string, env *Environment,
 			absOutputPath := Abs(path.Join(jobDir, "output.json"))
 
 			if v.ShouldSkip {
-				copyFiles := []string{"script.sh", "run.sh", "script.stdout", "script.stderr", "rc", "input.json", "output.json"}
+				copyFiles := []string{"script.sh", "run.sh", "script.stdout", "script.stderr", "rc", "input.json", "output.json", containerImageFileName, condaEnvironmentFileName, modulesFileName}
+				// files written only if a container, conda or modules is used
+				optionalFiles := map[string]bool{containerImageFileName: true, condaEnvironmentFileName: true, modulesFileName: true}
 				for _, x := range copyFiles {
 					srcFile, err := os.Open(path.Join(v.ReuseLog.JobLogRoot, x))
-					if err != nil {
+					if os.IsNotExist(err) && optionalFiles[x] {
+						continue
+					} else if err != nil {
 						return nil, err
 					}
 					defer srcFile.Close()
@@ -231,6 +419,11 @@ func GenerateTaskScripts(scriptPath string, paramPath string, env *Environment,
 					absDependentFilesBuilder.WriteString(strconv.Quote(v))
 					absDependentFilesBuilder.WriteString(" ")
 				}
+				if v.CommandConfiguration.Singularity != "" {
+					// record Singularity image as an input to detect update of the image
+					absDependentFilesBuilder.WriteString(strconv.Quote(v.CommandConfiguration.Singularity))
+					absDependentFilesBuilder.WriteString(" ")
+				}
 				absDependentFiles := absDependentFilesBuilder.String()
 
 				var absCreatingFilesBuilder strings.Builder
@@ -258,6 +451,9 @@ export LD_LIBRARY_PATH="%s"
 `, pathEnv, ldLibraryPathEnv)
 				}
 
+				writeEnvironmentVariables(runFile, v.Environment)
+				writeTaskEnvironmentScript(runFile, &v.CommandConfiguration, Abs(jobDir))
+
 				skipSha := ""
 				if env.skipSha {
 					skipSha = " -skipSha "
@@ -265,9 +461,18 @@ export LD_LIBRARY_PATH="%s"
 
 				fmt.Fprintf(runFile, "%s filelog %s -output %s %s || exit 1\n", shellflowPath, skipSha, absInputPath, absDependentFiles)
 
-				fmt.Fprintf(runFile, `/bin/bash -o pipefail -e "%s" > %s 2> %s
+				if v.CommandConfiguration.Container != "" {
+					writeContainerImageScript(runFile, &v.CommandConfiguration, Abs(jobDir))
+				}
+
+				command := scriptCommand(&v.CommandConfiguration, env.workDir, Abs(jobDir), v.Environment)
+				if v.CommandConfiguration.Retry > 0 {
+					writeRetryScript(runFile, Abs(jobDir), command, &v.CommandConfiguration)
+				} else {
+					fmt.Fprintf(runFile, `%s > %s 2> %s
 EXIT_CODE=$?
-`, absScriptPath, absStdoutPath, absStderrPath)
+`, command, absStdoutPath, absStderrPath)
+				}
 
 				skipSha = ""
 				if env.skipSha {
@@ -306,6 +511,49 @@ EXIT_CODE=$?
 	return &ret, nil
 }
 
+// writeRetryScript writes a part of run.sh which runs script.sh until it
+// succeeds or the number of attempts exceeds the retry limit.
+// Outputs of each attempt are moved into attemptN directories, and outputs of
+// the last attempt are copied back when all attempts are finished. When run.sh
+// is started again by a job scheduler, an output of an interrupted attempt,
+// whose attemptN directory has no rc file, is archived with return code 1000.
+func writeRetryScript(w io.Writer, jobDir string, command string, conf *CommandConfiguration) {
+	retryCodes := make([]string, len(conf.RetryOnExitCodes))
+	for i, x := range conf.RetryOnExitCodes {
+		retryCodes[i] = strconv.Itoa(x)
+	}
+
+	fmt.Fprintf(w, `JOB_DIR="%s"
+MAX_ATTEMPT=%d
+RETRY_ON_EXIT_CODES="%s"
+ATTEMPT=$(ls -d "$JOB_DIR"/attempt* 2> /dev/null | wc -l)
+if [ $ATTEMPT -gt 0 ] && [ ! -e "$JOB_DIR/attempt$ATTEMPT/rc" ]; then
+    mv "$JOB_DIR/script.stdout" "$JOB_DIR/script.stderr" "$JOB_DIR/attempt$ATTEMPT/" 2> /dev/null
+    echo 1000 > "$JOB_DIR/attempt$ATTEMPT/rc"
+fi
+rm -f "$JOB_DIR/script.stdout" "$JOB_DIR/script.stderr"
+while true; do
+    ATTEMPT=$((ATTEMPT + 1))
+    mkdir -p "$JOB_DIR/attempt$ATTEMPT"
+    %s > "$JOB_DIR/script.stdout" 2> "$JOB_DIR/script.stderr"
+    EXIT_CODE=$?
+    mv "$JOB_DIR/script.stdout" "$JOB_DIR/script.stderr" "$JOB_DIR/attempt$ATTEMPT/"
+    echo $EXIT_CODE > "$JOB_DIR/attempt$ATTEMPT/rc"
+    if [ $EXIT_CODE -eq 0 ] || [ $ATTEMPT -ge $MAX_ATTEMPT ]; then
+        break
+    fi
+    if [ -n "$RETRY_ON_EXIT_CODES" ]; then
+        case " $RETRY_ON_EXIT_CODES " in
+            *" $EXIT_CODE "*) ;;
+            *) break ;;
+        esac
+    fi
+    echo "Retry: attempt $ATTEMPT failed with exit code $EXIT_CODE" 1>&2
+done
+cp "$JOB_DIR/attempt$ATTEMPT/script.stdout" "$JOB_DIR/attempt$ATTEMPT/script.stderr" "$JOB_DIR/"
+`, jobDir, conf.Retry+1, strings.Join(retryCodes, " "), command)
+}
+
 func Abs(path string) string {
 	abs, err := filepath.Abs(path)
 	if err != nil {
@@ -1,10 +1,14 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"path"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/informationsea/shellflow/flowscript"
@@ -110,3 +114,173 @@ func TestGenerateTaskScripts(t *testing.T) {
 
 	os.RemoveAll(tempdir)
 }
+
+func TestGetExecuter(t *testing.T) {
+	if names := ExecuterNames(); !reflect.DeepEqual(names, []string{"condor", "local", "lsf", "pbs", "sge", "slurm"}) {
+		t.Fatalf("bad executer names: %s", names)
+	}
+
+	if e, err := GetExecuter(""); err != nil || e != executers["local"] {
+		t.Fatalf("default executer should be local: %s", err)
+	}
+
+	if e, err := GetExecuter("sge"); err != nil || e != executers["sge"] {
+		t.Fatalf("cannot get sge executer: %s", err)
+	}
+
+	if _, err := GetExecuter("unknown"); err == nil || err.Error() != "Unknown backend type: unknown (available: condor, local, lsf, pbs, sge, slurm)" {
+		t.Fatalf("bad error: %s", err)
+	}
+}
+
+func TestJobStatus(t *testing.T) {
+	jobRoot, err := ioutil.TempDir("", "job_status")
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	defer os.RemoveAll(jobRoot)
+
+	if state, err := JobStatus(jobRoot); err != nil || state != JobPending {
+		t.Fatalf("bad state: %s %s", state, err)
+	}
+
+	err = ioutil.WriteFile(path.Join(jobRoot, localRunPidFile), []byte(fmt.Sprintf("%d", os.Getpid())), 0644)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	if state, err := JobStatus(jobRoot); err != nil || state != JobRunning {
+		t.Fatalf("bad state: %s %s", state, err)
+	}
+
+	err = ioutil.WriteFile(path.Join(jobRoot, "rc"), []byte("1\n"), 0644)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	if state, err := JobStatus(jobRoot); err != nil || state != JobFailed {
+		t.Fatalf("bad state: %s %s", state, err)
+	}
+}
+
+func TestExecuteWithRetry(t *testing.T) {
+	ClearCache()
+	tmp, err := NewTempDir("retry")
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	os.Args[0] = path.Join(tmp.originalCwd, "shellflow")
+	defer tmp.Close()
+
+	err = ioutil.WriteFile("shellflow.toml", []byte(`[[Command]]
+RegExp = "flaky"
+Retry = 2
+
+[[Command]]
+RegExp = "fatal"
+Retry = 2
+RetryOnExitCodes = [3]
+`), 0644)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	testScript := `echo flaky >> count; test $(wc -l < count) -ge 2; echo ok > [[a]]
+echo fatal >> count2; exit 4
+`
+	env := NewEnvironment()
+	builder, err := ParseShellflow(strings.NewReader(testScript), env, make(map[string]interface{}))
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	gen, err := GenerateTaskScripts("retry.sf", "", env, builder)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	err = ExecuteLocalSingle(gen)
+	if err == nil || !IsExecutionError(err) {
+		t.Fatalf("execution error should be returned: %s", err)
+	}
+
+	log, err := CollectLogsForOneWork(gen.workflowRoot)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	if log.JobLogs[0].State() != JobDone || log.JobLogs[0].Attempts != 2 {
+		t.Fatalf("bad job log: %s %d", log.JobLogs[0].State(), log.JobLogs[0].Attempts)
+	}
+	if log.JobLogs[1].State() != JobFailed || log.JobLogs[1].Attempts != 1 || log.JobLogs[1].ExitCode != 4 {
+		t.Fatalf("bad job log: %s %d", log.JobLogs[1].State(), log.JobLogs[1].Attempts)
+	}
+
+	rc, err := ioutil.ReadFile(path.Join(gen.scripts[1].JobRoot, "attempt1", "rc"))
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	if string(rc) != "1\n" {
+		t.Fatalf("bad rc: %s", rc)
+	}
+}
+
+func TestRetryScriptRestart(t *testing.T) {
+	tmp, err := NewTempDir("retry_restart")
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	defer tmp.Close()
+
+	jobDir := Abs("job")
+	err = os.MkdirAll(path.Join(jobDir, "attempt1"), 0755)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	// attempt 1 is finished and attempt 2 is interrupted
+	for k, v := range map[string]string{
+		"attempt1/rc":            "1\n",
+		"attempt1/script.stdout": "first\n",
+		"attempt1/script.stderr": "",
+		"script.stdout":          "second\n",
+		"script.stderr":          "",
+	} {
+		err = ioutil.WriteFile(path.Join(jobDir, k), []byte(v), 0644)
+		if err != nil {
+			t.Fatalf("error: %s", err.Error())
+		}
+	}
+	err = os.MkdirAll(path.Join(jobDir, "attempt2"), 0755)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	run := func() {
+		var script bytes.Buffer
+		writeRetryScript(&script, jobDir, "echo ok", &CommandConfiguration{Retry: 3})
+		err = exec.Command("/bin/bash", "-c", script.String()).Run()
+		if err != nil {
+			t.Fatalf("error: %s", err.Error())
+		}
+	}
+
+	run()
+	for k, v := range map[string]string{
+		"attempt2/rc":            "1000\n",
+		"attempt2/script.stdout": "second\n",
+		"attempt3/rc":            "0\n",
+		"attempt3/script.stdout": "ok\n",
+		"script.stdout":          "ok\n",
+	} {
+		data, err := ioutil.ReadFile(path.Join(jobDir, k))
+		if err != nil || string(data) != v {
+			t.Fatalf("bad %s: %s %s", k, data, err)
+		}
+	}
+
+	// restart after all attempts are finished does not archive a fake attempt
+	run()
+	if attempts, err := countAttempts(jobDir); err != nil || attempts != 4 {
+		t.Fatalf("bad attempts: %d %s", attempts, err)
+	}
+	if data, err := ioutil.ReadFile(path.Join(jobDir, "attempt4", "rc")); err != nil || string(data) != "0\n" {
+		t.Fatalf("bad rc: %s %s", data, err)
+	}
+}
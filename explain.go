@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// TaskExplanation is a reuse decision of a task
+type TaskExplanation struct {
+	ID          int
+	LineNum     int
+	ShellScript string
+	Reuse       bool
+	ReuseLog    string   `json:",omitempty"`
+	Reasons     []string `json:",omitempty"`
+}
+
+// ExplainTasks collects reuse decisions of tasks in a workflow
+func ExplainTasks(builder *ShellTaskBuilder) []TaskExplanation {
+	explanations := make([]TaskExplanation, 0, len(builder.Tasks))
+	for _, v := range builder.Tasks {
+		explanation := TaskExplanation{
+			ID:          v.ID,
+			LineNum:     v.LineNum,
+			ShellScript: v.ShellScript,
+			Reuse:       v.ShouldSkip,
+		}
+		if v.ShouldSkip && v.ReuseLog != nil {
+			explanation.ReuseLog = v.ReuseLog.JobLogRoot
+		} else {
+			explanation.Reasons = builder.RerunReasons(v.ID)
+		}
+		explanations = append(explanations, explanation)
+	}
+	return explanations
+}
+
+// WriteExplanationTable writes reuse decisions as a table. Reasons are
+// written in following lines of each task.
+func WriteExplanationTable(w io.Writer, explanations []TaskExplanation) error {
+	_, err := fmt.Fprintf(w, "%3s|%4s|%-8s|Command\n", "#", "Line", "Decision")
+	if err != nil {
+		return err
+	}
+	for _, v := range explanations {
+		decision := "Run"
+		details := v.Reasons
+		if v.Reuse {
+			decision = "Reuse"
+			details = []string{"reuse " + v.ReuseLog}
+		}
+		_, err = fmt.Fprintf(w, "%3d|%4d|%-8s|%s\n", v.ID, v.LineNum, decision, strings.Replace(v.ShellScript, "\n", " ", -1))
+		if err != nil {
+			return err
+		}
+		for _, x := range details {
+			_, err = fmt.Fprintf(w, "%3s|%4s|%8s|  - %s\n", "", "", "", x)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// WriteExplanationJSON writes reuse decisions as JSON
+func WriteExplanationJSON(w io.Writer, explanations []TaskExplanation) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(explanations)
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExplainTasks(t *testing.T) {
+	ClearCache()
+	tmp, err := NewTempDir("explain")
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	os.Args[0] = path.Join(tmp.originalCwd, "shellflow")
+	defer tmp.Close()
+
+	err = ioutil.WriteFile("input", []byte("hello\n"), 0644)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	parse := func(script string) *ShellTaskBuilder {
+		ClearCache()
+		builder, err := ParseShellflow(strings.NewReader(script), NewEnvironment(), make(map[string]interface{}))
+		if err != nil {
+			t.Fatalf("error: %s", err.Error())
+		}
+		return builder
+	}
+
+	testScript := `cat ((input)) > [[a]]
+cat ((a)) > [[b]]
+sort ((input)) > [[c]]
+echo ok > [[d]]
+exit 1
+`
+	builder := parse(testScript)
+	gen, err := GenerateTaskScripts("explain.sf", "", NewEnvironment(), builder)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	err = ExecuteLocalSingle(gen)
+	if err == nil || !IsExecutionError(err) {
+		t.Fatalf("execution error should be returned: %s", err)
+	}
+
+	err = ioutil.WriteFile("input", []byte("hello, world\n"), 0644)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	err = os.Remove("d")
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	builder = parse(strings.Replace(testScript, "sort ((input))", "sort -r ((input))", 1))
+	explanations := ExplainTasks(builder)
+	expected := []TaskExplanation{
+		{ID: 1, LineNum: 1, ShellScript: "cat input > a", Reasons: []string{"input input is changed (size is changed)"}},
+		{ID: 2, LineNum: 2, ShellScript: "cat a > b", Reasons: []string{"upstream task 1 is run"}},
+		{ID: 3, LineNum: 3, ShellScript: "sort -r input > c", Reasons: []string{"command script differs from previous job"}},
+		{ID: 4, LineNum: 4, ShellScript: "echo ok > d", Reasons: []string{"output d is missing"}},
+		{ID: 5, LineNum: 5, ShellScript: "exit 1", Reasons: []string{"previous job failed with exit code 1"}},
+	}
+	if !reflect.DeepEqual(explanations, expected) {
+		t.Fatalf("bad explanations: %v", explanations)
+	}
+
+	var buf bytes.Buffer
+	err = WriteExplanationJSON(&buf, explanations)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	var decoded []TaskExplanation
+	err = json.Unmarshal(buf.Bytes(), &decoded)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	if !reflect.DeepEqual(decoded, expected) {
+		t.Fatalf("bad JSON: %s", buf.String())
+	}
+
+	// reused task
+	builder = parse("echo ok > [[d]]\n")
+	builder.Tasks[0].ShouldSkip = true
+	builder.Tasks[0].ReuseLog = &JobLog{JobLogRoot: "shellflow-wf/x/job004"}
+
+	buf.Reset()
+	err = WriteExplanationTable(&buf, append(ExplainTasks(builder), expected[4]))
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	if v := buf.String(); v != `  #|Line|Decision|Command
+  1|   1|Reuse   |echo ok > d
+   |    |        |  - reuse shellflow-wf/x/job004
+  5|   5|Run     |exit 1
+   |    |        |  - previous job failed with exit code 1
+` {
+		t.Fatalf("bad table: %s", v)
+	}
+
+	// vanished job
+	vanished := &JobLog{IsStarted: true, IsDone: true, ExitCode: 1000, ScriptExitCode: 0}
+	if reasons := vanished.notReusableReasons(); !reflect.DeepEqual(reasons, []string{"previous job vanished (exit code 1000)"}) {
+		t.Fatalf("bad reasons: %s", reasons)
+	}
+}
+
+func TestExplainSelectedTasks(t *testing.T) {
+	ClearCache()
+	tmp, err := NewTempDir("explain_selected")
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	os.Args[0] = path.Join(tmp.originalCwd, "shellflow")
+	defer tmp.Close()
+
+	testScript := `echo a > [[a]]
+cat ((a)) > [[b]]
+echo c > [[c]]
+`
+	builder, err := ParseShellflow(strings.NewReader(testScript), NewEnvironment(), make(map[string]interface{}))
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	gen, err := GenerateTaskScripts("explain.sf", "", NewEnvironment(), builder)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	err = ExecuteLocalSingle(gen)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	ClearCache()
+	builder, err = ParseShellflow(strings.NewReader(testScript), NewEnvironment(), make(map[string]interface{}))
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	selection := taskSelectionFlags{untilLines: intListFlag{2}, rerunTasks: stringListFlag{"file:a"}}
+	err = selection.apply(builder)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	explanations := ExplainTasks(builder)
+	expected := []TaskExplanation{
+		{ID: 1, LineNum: 1, ShellScript: "echo a > a", Reasons: []string{"rerun is requested"}},
+		{ID: 2, LineNum: 2, ShellScript: "cat a > b", Reasons: []string{"upstream task 1 is run"}},
+	}
+	if !reflect.DeepEqual(explanations, expected) {
+		t.Fatalf("bad explanations: %v", explanations)
+	}
+}
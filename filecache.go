@@ -72,6 +72,34 @@ func NewSha256Cache() *Sha256Cache {
 
 var Sha256CacheConnection *Sha256Cache
 
+// lookupSha256Cache returns SHA256 of a file registered in the cache.
+// nil is returned if the file is not registered.
+func lookupSha256Cache(filepath string, stat os.FileInfo) HashSum {
+	if Sha256CacheConnection == nil {
+		return nil
+	}
+	result := Sha256CacheConnection.Connection.QueryRow("SELECT sha256 FROM Sha256Cache WHERE path = ? AND modified = ? AND size = ?", filepath, stat.ModTime().String(), stat.Size())
+	var hashString string
+	err := result.Scan(&hashString)
+	if err == nil {
+		var hashSum HashSum
+		_, err = fmt.Sscanf(hashString, "%x", &hashSum)
+		return hashSum
+	}
+	if err != sql.ErrNoRows {
+		fmt.Fprintf(os.Stderr, "(Ignored) Cannot scan sqlite database: %s\n", err)
+	}
+	return nil
+}
+
+// HashFile calculates SHA256 of a file to compare it with a log. The result
+// is registered to the cache like CalcSha256ForFile, but contents of the file
+// are not backed up.
+func HashFile(filepath string) (HashSum, error) {
+	// negative size limit disables backup of any file
+	return CalcSha256ForFile(filepath, -1)
+}
+
 func CalcSha256ForFile(filepath string, maximumContentLogSize int64) (HashSum, error) {
 	if Sha256CacheConnection == nil {
 		Sha256CacheConnection = NewSha256Cache()
@@ -82,19 +110,8 @@ func CalcSha256ForFile(filepath string, maximumContentLogSize int64) (HashSum, e
 		return nil, err
 	}
 
-	if Sha256CacheConnection != nil {
-		result := Sha256CacheConnection.Connection.QueryRow("SELECT sha256 FROM Sha256Cache WHERE path = ? AND modified = ? AND size = ?", filepath, stat.ModTime().String(), stat.Size())
-		var hashString string
-		err = result.Scan(&hashString)
-		if err == nil {
-			var hashSum HashSum
-			_, err = fmt.Sscanf(hashString, "%x", &hashSum)
-			return hashSum, nil
-		}
-		if err != sql.ErrNoRows {
-			fmt.Fprintf(os.Stderr, "(Ignored) Cannot scan sqlite database: %s\n", err)
-			//return nil, err
-		}
+	if hashSum := lookupSha256Cache(filepath, stat); hashSum != nil {
+		return hashSum, nil
 	}
 
 	backupContent := stat.Size() <= maximumContentLogSize
@@ -107,7 +124,7 @@ func CalcSha256ForFile(filepath string, maximumContentLogSize int64) (HashSum, e
 	}
 	defer file.Close()
 
-	fmt.Printf("calculating SHA256 for %s\n", filepath)
+	fmt.Fprintf(os.Stderr, "calculating SHA256 for %s\n", filepath)
 
 	if backupContent {
 		reader = io.TeeReader(file, content)
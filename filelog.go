@@ -90,30 +90,67 @@ func CreateFileLog(files []string, skipSha bool, maximumContentLogSize int64) ([
 	return fileLogs, nil
 }
 
-var isChangedCache = make(map[string]bool)
+// CompareContentHash enables to recalculate SHA256 of a file whose
+// modification date is changed. The file is regarded as unchanged if SHA256
+// is same as recorded one.
+var CompareContentHash = false
+
+// FileChange is a result of comparing a file with its log
+type FileChange int
+
+const (
+	// FileUnchanged means modification date and size are not changed
+	FileUnchanged FileChange = iota
+	// FileTouched means modification date is changed but contents are not changed
+	FileTouched
+	// FileChanged means a file is removed or its contents are changed
+	FileChanged
+)
 
 // IsChanged function check whether the file is changed or not.
 // Return true if the file is changed.
-// This function does not check SHA256, but only existance and modification date
+// This function checks only existance and modification date unless
+// CompareContentHash is enabled.
 func (v *FileLog) IsChanged() (bool, error) {
-	//if changed, ok := isChangedCache[v.Relpath]; ok {
-	//		return changed, nil
-	//	}
+	change, err := v.CheckChange()
+	return change == FileChanged, err
+}
+
+// CheckChange compares the file with the log. If CompareContentHash is
+// enabled, SHA256 of a file whose modification date is changed is compared
+// with recorded one.
+func (v *FileLog) CheckChange() (FileChange, error) {
+	change, _, err := v.ExplainChange()
+	return change, err
+}
 
+// ExplainChange compares the file with the log like CheckChange, and
+// describes what is changed.
+func (v *FileLog) ExplainChange() (FileChange, string, error) {
 	stat, err := Stat(v.Relpath)
-	changed := false
 	if err != nil && os.IsNotExist(err) {
-		changed = true
-		return true, nil
+		return FileChanged, "removed", nil
 	} else if err != nil {
-		return true, err
+		return FileChanged, "", err
+	}
+	if stat.Size() != v.Size {
+		return FileChanged, "size is changed", nil
 	}
-	if stat.ModTime().Unix() != v.Modified.Unix() || stat.ModTime().UnixNano() != v.Modified.UnixNano() || stat.Size() != v.Size {
-		changed = true
+	if stat.ModTime().Unix() != v.Modified.Unix() || stat.ModTime().UnixNano() != v.Modified.UnixNano() {
+		if CompareContentHash && !stat.IsDir() && len(v.Sha256Sum) > 0 {
+			hash, err := HashFile(v.Relpath)
+			if err != nil {
+				return FileChanged, "", err
+			}
+			if bytes.Equal(hash, v.Sha256Sum) {
+				return FileTouched, "modification date is changed but SHA256 is same", nil
+			}
+			return FileChanged, "SHA256 is changed", nil
+		}
+		return FileChanged, "modification date is changed", nil
 	}
 
-	isChangedCache[v.Relpath] = changed
-	return changed, nil
+	return FileUnchanged, "", nil
 }
 
 func (v HashSum) MarshalJSON() ([]byte, error) {
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -8,6 +9,7 @@ import (
 	"os"
 	"path"
 	"testing"
+	"time"
 )
 
 var filelogFiles = []string{"./examples/hello.c", "./examples/helloprint.c", "./examples/helloprint.h"}
@@ -180,3 +182,112 @@ func TestFileLog(t *testing.T) {
 	}
 	defer tmp.Close()
 }
+
+func TestFileLogContentHash(t *testing.T) {
+	ClearCache()
+	tmp, err := NewTempDir("filelog_hash")
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	defer tmp.Close()
+	defer func() { CompareContentHash = false }()
+
+	logs, err := CreateFileLog(filelogFiles, false, 100)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	// comparing contents does not write backup
+	err = os.RemoveAll(WorkflowLogDir)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	for _, v := range logs {
+		touched := v.Modified.Add(time.Hour)
+		err = os.Chtimes(v.Relpath, touched, touched)
+		if err != nil {
+			t.Fatalf("error: %s", err.Error())
+		}
+
+		for _, x := range []struct {
+			compareHash bool
+			expected    FileChange
+		}{
+			{false, FileChanged},
+			{true, FileTouched},
+		} {
+			ClearCache()
+			CompareContentHash = x.compareHash
+			if result, err := v.CheckChange(); err != nil || result != x.expected {
+				t.Fatalf("bad change result for %s with hash mode %v: %d / %s", v.Relpath, x.compareHash, result, err)
+			}
+			if result, err := v.IsChanged(); err != nil || result != (x.expected == FileChanged) {
+				t.Fatalf("bad is changed result for %s: %s", v.Relpath, err)
+			}
+		}
+
+		// same size but different contents
+		data, err := ioutil.ReadFile(v.Relpath)
+		if err != nil {
+			t.Fatalf("error: %s", err.Error())
+		}
+		data[0]++
+		err = ioutil.WriteFile(v.Relpath, data, 0644)
+		if err != nil {
+			t.Fatalf("error: %s", err.Error())
+		}
+
+		ClearCache()
+		if result, err := v.CheckChange(); err != nil || result != FileChanged {
+			t.Fatalf("bad change result for modified %s: %d / %s", v.Relpath, result, err)
+		}
+	}
+
+	if _, err := os.Stat(path.Join(WorkflowLogDir, "__backup")); !os.IsNotExist(err) {
+		t.Fatalf("backup directory should not be created: %v", err)
+	}
+}
+
+func TestHashFileCache(t *testing.T) {
+	ClearCache()
+	tmp, err := NewTempDir("hash_cache")
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	defer tmp.Close()
+
+	err = ioutil.WriteFile("a", []byte("hello"), 0644)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	stat, err := os.Stat("a")
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	hash, err := HashFile("a")
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	// same size and modification date
+	err = ioutil.WriteFile("a", []byte("world"), 0644)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	err = os.Chtimes("a", stat.ModTime(), stat.ModTime())
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	// SHA256 registered to the cache is used instead of rehashing the file
+	cached, err := HashFile("a")
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	if !bytes.Equal(cached, hash) {
+		t.Fatalf("file should not be rehashed: %x %x", cached, hash)
+	}
+	if _, err := os.Stat(path.Join(WorkflowLogDir, "__backup")); !os.IsNotExist(err) {
+		t.Fatalf("backup directory should not be created: %v", err)
+	}
+}
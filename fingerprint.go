@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const fingerprintFileName = "fingerprint.json"
+
+// TaskFingerprint summarizes things other than input files which can change
+// results of a task. A job is reused only if its fingerprint is same.
+type TaskFingerprint struct {
+	Script        string
+	Configuration string
+	Environment   string
+	Executables   map[string]ExecutableFingerprint
+}
+
+// ExecutableFingerprint is a path and SHA256 of a command invoked in a task.
+// Path is empty if the command is not found.
+type ExecutableFingerprint struct {
+	Path      string
+	Sha256Sum HashSum `json:",omitempty"`
+}
+
+// NewTaskFingerprint creates a fingerprint of a task. Executables are not
+// recorded if a task runs in a container, because ID of an image is checked
+// instead.
+func NewTaskFingerprint(shellScript string, conf *CommandConfiguration, environment map[string]string) (*TaskFingerprint, error) {
+	// Rule source and pattern do not change results
+	resolved := *conf
+	resolved.RegExp = ""
+	resolved.Source = ""
+	confJSON, err := json.Marshal(resolved)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot encode configuration: %s", err.Error())
+	}
+
+	// PATH and LD_LIBRARY_PATH are exported in run.sh
+	exported := make(map[string]string)
+	for k, v := range environment {
+		exported[k] = v
+	}
+	if !conf.DontInheirtPath {
+		exported["PATH"] = os.Getenv("PATH")
+		exported["LD_LIBRARY_PATH"] = os.Getenv("LD_LIBRARY_PATH")
+	}
+	envJSON, err := json.Marshal(exported)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot encode environment variables: %s", err.Error())
+	}
+
+	executables := make(map[string]ExecutableFingerprint)
+	if conf.Container == "" && conf.Singularity == "" {
+		for _, v := range invokedCommands(shellScript) {
+			executables[v] = executableFingerprint(v)
+		}
+	}
+
+	return &TaskFingerprint{
+		Script:        sha256String(shellScript),
+		Configuration: sha256String(string(confJSON)),
+		Environment:   sha256String(string(envJSON)),
+		Executables:   executables,
+	}, nil
+}
+
+func writeFingerprint(jobDir string, fingerprint *TaskFingerprint) error {
+	file, err := os.OpenFile(path.Join(jobDir, fingerprintFileName), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("Cannot create fingerprint file: %s", err.Error())
+	}
+	defer file.Close()
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(fingerprint)
+}
+
+// loadFingerprint loads a fingerprint in a job log directory. nil is
+// returned if a job is logged before fingerprint was introduced.
+func loadFingerprint(jobDir string) (*TaskFingerprint, error) {
+	var fingerprint TaskFingerprint
+	err := LoadJsonFromFile(path.Join(jobDir, fingerprintFileName), &fingerprint)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Cannot load fingerprint: %s", err.Error())
+	}
+	return &fingerprint, nil
+}
+
+func sha256String(s string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(s)))
+}
+
+func executableFingerprint(command string) ExecutableFingerprint {
+	path, err := exec.LookPath(command)
+	if err != nil {
+		return ExecutableFingerprint{}
+	}
+	// size limit zero disables backup of executable
+	hash, err := CalcSha256ForFile(path, 0)
+	if err != nil {
+		return ExecutableFingerprint{Path: path}
+	}
+	return ExecutableFingerprint{Path: path, Sha256Sum: hash}
+}
+
+// Differences explains why old fingerprint is not same as this fingerprint.
+// Empty array is returned if they are same.
+func (v *TaskFingerprint) Differences(old *TaskFingerprint) []string {
+	differences := make([]string, 0)
+	if v.Script != old.Script {
+		differences = append(differences, "command script is changed")
+	}
+	if v.Configuration != old.Configuration {
+		differences = append(differences, "command configuration is changed")
+	}
+	if v.Environment != old.Environment {
+		differences = append(differences, "exported environment variables are changed")
+	}
+
+	commands := make([]string, 0)
+	for k := range v.Executables {
+		commands = append(commands, k)
+	}
+	sort.Strings(commands)
+	for _, k := range commands {
+		current := v.Executables[k]
+		previous, ok := old.Executables[k]
+		switch {
+		case !ok:
+			differences = append(differences, fmt.Sprintf("executable %s is not recorded", k))
+		case current.Path == "" && previous.Path != "":
+			differences = append(differences, fmt.Sprintf("executable %s is not found", k))
+		case previous.Path == "" && current.Path != "":
+			differences = append(differences, fmt.Sprintf("executable %s is found at %s", k, current.Path))
+		case current.Path != previous.Path:
+			differences = append(differences, fmt.Sprintf("executable %s is moved from %s to %s", k, previous.Path, current.Path))
+		case !bytes.Equal(current.Sha256Sum, previous.Sha256Sum):
+			differences = append(differences, fmt.Sprintf("executable %s (%s) is changed", k, current.Path))
+		}
+	}
+	return differences
+}
+
+var commandSeparatorRegexp = regexp.MustCompile("\\|\\||&&|\\$\\(|[|;&(`\\n]")
+var assignmentRegexp = regexp.MustCompile("^[A-Za-z_][A-Za-z0-9_]*=")
+
+// shellKeywords are skipped to find a command name
+var shellKeywords = map[string]bool{
+	"!": true, "if": true, "then": true, "else": true, "elif": true, "fi": true,
+	"while": true, "until": true, "do": true, "done": true, "esac": true,
+	"time": true, "function": true, "exec": true,
+	"command": true, "nohup": true, "env": true, "{": true, "}": true,
+}
+
+// shellBuiltins are not recorded as executables
+var shellBuiltins = map[string]bool{
+	".": true, ":": true, "[": true, "[[": true, "alias": true, "cd": true,
+	"echo": true, "eval": true, "exit": true, "export": true, "false": true,
+	"local": true, "printf": true, "pwd": true, "read": true, "return": true,
+	"set": true, "shift": true, "source": true, "test": true, "trap": true,
+	"true": true, "umask": true, "unset": true, "wait": true,
+}
+
+// invokedCommands extracts names of commands invoked in a shell script.
+// Commands which name contains shell expansion are ignored.
+func invokedCommands(shellScript string) []string {
+	found := make(map[string]bool)
+	for _, segment := range commandSeparatorRegexp.Split(shellScript, -1) {
+		for _, word := range strings.Fields(segment) {
+			if shellKeywords[word] || assignmentRegexp.MatchString(word) {
+				continue
+			}
+			if word == "for" || word == "case" || word == "select" {
+				// a list of words follows
+				break
+			}
+			word = strings.Trim(word, "\"')")
+			if word != "" && !shellBuiltins[word] && !strings.ContainsAny(word, "$*?<>=") {
+				found[word] = true
+			}
+			break
+		}
+	}
+
+	commands := make([]string, 0, len(found))
+	for k := range found {
+		commands = append(commands, k)
+	}
+	sort.Strings(commands)
+	return commands
+}
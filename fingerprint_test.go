@@ -0,0 +1,124 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInvokedCommands(t *testing.T) {
+	testCases := map[string][]string{
+		"bwa mem ref.fa a.fq | samtools sort -o a.bam":    []string{"bwa", "samtools"},
+		"LANG=C sort a > b && echo done":                  []string{"sort"},
+		"test $(wc -l < count) -ge 2; ./run.sh":           []string{"./run.sh", "wc"},
+		"if true; then { gzip -c a; } fi":                 []string{"gzip"},
+		"env TMPDIR=/tmp java -jar picard.jar || $HOME/x": []string{"java"},
+		"(cd work && make) & wait":                        []string{"make"},
+		"for i in 1 2; do time \"python3\" x.py $i; done": []string{"python3"},
+	}
+
+	for k, v := range testCases {
+		if commands := invokedCommands(k); !reflect.DeepEqual(commands, v) {
+			t.Fatalf("bad commands for %s: %s", k, commands)
+		}
+	}
+}
+
+func TestTaskFingerprint(t *testing.T) {
+	ClearCache()
+	fakeDir, cleanup := setupFakeCommands(t, map[string]string{"mytool": "#!/bin/bash\necho mytool\n"})
+	defer cleanup()
+
+	tmp, err := NewTempDir("fingerprint")
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	os.Args[0] = path.Join(tmp.originalCwd, "shellflow")
+	defer tmp.Close()
+
+	testScript := `mytool > [[a]]
+cat ((a)) > [[b]]
+`
+	run := func() *TaskScripts {
+		ClearCache()
+		env := NewEnvironment()
+		builder, err := ParseShellflow(strings.NewReader(testScript), env, make(map[string]interface{}))
+		if err != nil {
+			t.Fatalf("error: %s", err.Error())
+		}
+
+		gen, err := GenerateTaskScripts("fingerprint.sf", "", env, builder)
+		if err != nil {
+			t.Fatalf("error: %s", err.Error())
+		}
+
+		err = ExecuteLocalSingle(gen)
+		if err != nil {
+			t.Fatalf("error: %s", err.Error())
+		}
+		return gen
+	}
+
+	gen := run()
+	log, err := CollectLogsForOneWork(gen.workflowRoot)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	if fingerprint := log.JobLogs[0].Fingerprint; fingerprint == nil || fingerprint.Executables["mytool"].Path != path.Join(fakeDir, "mytool") {
+		t.Fatalf("bad fingerprint: %v", fingerprint)
+	}
+	if reasons := gen.builder.RerunReasons(1); !reflect.DeepEqual(reasons, []string{"no previous log of the command"}) {
+		t.Fatalf("bad reasons: %s", reasons)
+	}
+
+	// nothing is changed
+	gen = run()
+	if !gen.builder.Tasks[0].ShouldSkip || !gen.builder.Tasks[1].ShouldSkip {
+		t.Fatalf("jobs should be reused")
+	}
+
+	// executable is updated
+	err = ioutil.WriteFile(path.Join(fakeDir, "mytool"), []byte("#!/bin/bash\necho mytool version 2\n"), 0755)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	gen = run()
+	if gen.builder.Tasks[0].ShouldSkip || gen.builder.Tasks[1].ShouldSkip {
+		t.Fatalf("jobs should not be reused")
+	}
+	if reasons := gen.builder.RerunReasons(1); !reflect.DeepEqual(reasons, []string{"executable mytool (" + path.Join(fakeDir, "mytool") + ") is changed"}) {
+		t.Fatalf("bad reasons: %s", reasons)
+	}
+	if reasons := gen.builder.RerunReasons(2); !reflect.DeepEqual(reasons, []string{"upstream task 1 is run"}) {
+		t.Fatalf("bad reasons: %s", reasons)
+	}
+
+	// configuration is changed
+	err = ioutil.WriteFile("shellflow.toml", []byte(`[[Command]]
+RegExp = "mytool"
+SGEOption = ["-l", "s_vmem=4G"]
+`), 0644)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	gen = run()
+	if gen.builder.Tasks[0].ShouldSkip {
+		t.Fatalf("job should not be reused")
+	}
+	if reasons := gen.builder.RerunReasons(1); !reflect.DeepEqual(reasons, []string{"command configuration is changed"}) {
+		t.Fatalf("bad reasons: %s", reasons)
+	}
+
+	// PATH is changed
+	os.Setenv("PATH", os.Getenv("PATH")+":/nonexistent")
+	gen = run()
+	if gen.builder.Tasks[0].ShouldSkip {
+		t.Fatalf("job should not be reused")
+	}
+	if reasons := gen.builder.RerunReasons(1); !reflect.DeepEqual(reasons, []string{"exported environment variables are changed"}) {
+		t.Fatalf("bad reasons: %s", reasons)
+	}
+}
@@ -25,6 +25,10 @@ func (v BadValue) AsInt() (int64, error) {
 	return 0, errors.New("Cannot convert bad value to int")
 }
 
+func (v BadValue) AsBool() (bool, error) {
+	return false, errors.New("Cannot convert bad value to bool")
+}
+
 func TestScriptFunctionCallBasename(t *testing.T) {
 	if BuiltinFunctions["basename"].name != "basename" {
 		t.Fatalf("Invalid function name: %s", BuiltinFunctions["basename"].name)
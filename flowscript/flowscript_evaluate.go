@@ -247,6 +247,169 @@ func (x *NumericOperationExpression) SubEvaluable() []Evaluable {
 	return evaluables[:]
 }
 
+type ComparisonExpression struct {
+	exp1     Evaluable
+	exp2     Evaluable
+	operator string
+}
+
+func (v *ComparisonExpression) String() string {
+	return fmt.Sprintf("%s %s %s", v.exp1, v.operator, v.exp2)
+}
+
+func compareValues(r1 Value, r2 Value) (int, error) {
+	_, isBool1 := r1.(BoolValue)
+	_, isBool2 := r2.(BoolValue)
+	if isBool1 || isBool2 {
+		b1, e1 := r1.AsBool()
+		b2, e2 := r2.AsBool()
+		if e1 != nil || e2 != nil {
+			return 0, fmt.Errorf("cannot compare %s and %s", r1, r2)
+		}
+		if b1 == b2 {
+			return 0, nil
+		} else if !b1 {
+			return -1, nil
+		}
+		return 1, nil
+	}
+
+	_, isInt1 := r1.(IntValue)
+	_, isInt2 := r2.(IntValue)
+	if isInt1 || isInt2 {
+		i1, e1 := r1.AsInt()
+		i2, e2 := r2.AsInt()
+		if e1 == nil && e2 == nil {
+			if i1 < i2 {
+				return -1, nil
+			} else if i1 > i2 {
+				return 1, nil
+			}
+			return 0, nil
+		}
+	}
+
+	s1, e1 := r1.AsString()
+	s2, e2 := r2.AsString()
+	if e1 != nil || e2 != nil {
+		return 0, fmt.Errorf("cannot compare %s and %s", r1, r2)
+	}
+	return strings.Compare(s1, s2), nil
+}
+
+func (v *ComparisonExpression) Evaluate(env Environment) (Value, error) {
+	r1, e := v.exp1.Evaluate(env)
+	if e != nil {
+		return nil, e
+	}
+	r2, e := v.exp2.Evaluate(env)
+	if e != nil {
+		return nil, e
+	}
+
+	c, e := compareValues(r1, r2)
+	if e != nil {
+		return nil, e
+	}
+
+	switch v.operator {
+	case "==":
+		return BoolValue{c == 0}, nil
+	case "!=":
+		return BoolValue{c != 0}, nil
+	case "<":
+		return BoolValue{c < 0}, nil
+	case "<=":
+		return BoolValue{c <= 0}, nil
+	case ">":
+		return BoolValue{c > 0}, nil
+	case ">=":
+		return BoolValue{c >= 0}, nil
+	}
+
+	return nil, fmt.Errorf("unknown operator %s", v.operator)
+}
+
+func (x *ComparisonExpression) SubEvaluable() []Evaluable {
+	evaluables := [...]Evaluable{x.exp1, x.exp2}
+	return evaluables[:]
+}
+
+type LogicalExpression struct {
+	exp1     Evaluable
+	exp2     Evaluable
+	operator string
+}
+
+func (v *LogicalExpression) String() string {
+	return fmt.Sprintf("%s %s %s", v.exp1, v.operator, v.exp2)
+}
+
+func (v *LogicalExpression) Evaluate(env Environment) (Value, error) {
+	r1, e := v.exp1.Evaluate(env)
+	if e != nil {
+		return nil, e
+	}
+	b1, e := r1.AsBool()
+	if e != nil {
+		return nil, e
+	}
+
+	// short circuit evaluation
+	switch v.operator {
+	case "&&":
+		if !b1 {
+			return BoolValue{false}, nil
+		}
+	case "||":
+		if b1 {
+			return BoolValue{true}, nil
+		}
+	default:
+		return nil, fmt.Errorf("unknown operator %s", v.operator)
+	}
+
+	r2, e := v.exp2.Evaluate(env)
+	if e != nil {
+		return nil, e
+	}
+	b2, e := r2.AsBool()
+	if e != nil {
+		return nil, e
+	}
+	return BoolValue{b2}, nil
+}
+
+func (x *LogicalExpression) SubEvaluable() []Evaluable {
+	evaluables := [...]Evaluable{x.exp1, x.exp2}
+	return evaluables[:]
+}
+
+type NotExpression struct {
+	exp Evaluable
+}
+
+func (v *NotExpression) String() string {
+	return fmt.Sprintf("!%s", v.exp)
+}
+
+func (v *NotExpression) Evaluate(env Environment) (Value, error) {
+	r, e := v.exp.Evaluate(env)
+	if e != nil {
+		return nil, e
+	}
+	b, e := r.AsBool()
+	if e != nil {
+		return nil, e
+	}
+	return BoolValue{!b}, nil
+}
+
+func (x *NotExpression) SubEvaluable() []Evaluable {
+	evaluables := [...]Evaluable{x.exp}
+	return evaluables[:]
+}
+
 type FunctionCall struct {
 	function Evaluable
 	args     []Evaluable
@@ -10,10 +10,15 @@ import (
 /*
  * Syntax
  * exp := <factor0> ; <factor0> | <factor0>
- * factor0 := <factor1> = <factor1> | <factor1>
+ * factor0 := <or> = <or> | <or>
+ * or := <and> || <or> | <and>
+ * and := <equality> && <and> | <equality>
+ * equality := <comparison> == <comparison> | <comparison> != <comparison> | <comparison>
+ * comparison := <factor1> < <factor1> | <factor1> <= <factor1> | <factor1> > <factor1> | <factor1> >= <factor1> | <factor1>
  * factor1 := <factor2> + <factor1> | <factor2> - <factor1> | <factor2>
- * factor2 := <factor3> * <factor2> | <factor3> / <factor2> | <factor3>
- * factor3 := <array_access> | <function_call> | <string> | <number> | <ident> | ( <exp> )
+ * factor2 := <not> * <factor2> | <not> / <factor2> | <not>
+ * not := ! <not> | <factor3>
+ * factor3 := <array_access> | <function_call> | <string> | <number> | <bool> | <ident> | ( <exp> )
  * array_access_or_array := <ident> [ <exp> ] | <array_access> [ <exp> ] | <array> [ <exp> ] | <array>
  * array := [ <exp> {, <exp>}* ]
  * function_call := <ident> ( {<exp> {, <exp>}*}? )
@@ -103,6 +108,15 @@ func ParseAsString(tokenizer *LookAheadScanner) (eval Evaluable, err error) {
 	})
 }
 
+func ParseAsBool(tokenizer *LookAheadScanner) (eval Evaluable, err error) {
+	return parserHelper(tokenizer, func(token []byte) bool {
+		text := string(token)
+		return text == "true" || text == "false"
+	}, func(token []byte) (eval Evaluable, err error) {
+		return ValueEvaluable{BoolValue{string(token) == "true"}}, nil
+	})
+}
+
 func ParseAsVariable(tokenizer *LookAheadScanner) (eval Evaluable, err error) {
 	return parserHelper(tokenizer, func(token []byte) bool {
 		return variableRegexp.Match(token)
@@ -128,7 +142,51 @@ var parseAsFactor0Map = map[string]binaryEvaluableCreator{
 }
 
 func ParseAsFactor0(tokenizer *LookAheadScanner) (eval Evaluable, err error) {
-	return binaryOperatorParserHelper(tokenizer, ParseAsFactor1, ParseAsFactor1, parseAsFactor0Map)
+	return binaryOperatorParserHelper(tokenizer, ParseAsOr, ParseAsOr, parseAsFactor0Map)
+}
+
+func createLogicalExpression(exp1 Evaluable, operator string, exp2 Evaluable) (eval Evaluable, err error) {
+	return &LogicalExpression{exp1: exp1, exp2: exp2, operator: operator}, nil
+}
+
+func createComparisonExpression(exp1 Evaluable, operator string, exp2 Evaluable) (eval Evaluable, err error) {
+	return &ComparisonExpression{exp1: exp1, exp2: exp2, operator: operator}, nil
+}
+
+var parseAsOrMap = map[string]binaryEvaluableCreator{
+	"||": createLogicalExpression,
+}
+
+func ParseAsOr(tokenizer *LookAheadScanner) (eval Evaluable, err error) {
+	return binaryOperatorParserHelper(tokenizer, ParseAsAnd, ParseAsOr, parseAsOrMap)
+}
+
+var parseAsAndMap = map[string]binaryEvaluableCreator{
+	"&&": createLogicalExpression,
+}
+
+func ParseAsAnd(tokenizer *LookAheadScanner) (eval Evaluable, err error) {
+	return binaryOperatorParserHelper(tokenizer, ParseAsEquality, ParseAsAnd, parseAsAndMap)
+}
+
+var parseAsEqualityMap = map[string]binaryEvaluableCreator{
+	"==": createComparisonExpression,
+	"!=": createComparisonExpression,
+}
+
+func ParseAsEquality(tokenizer *LookAheadScanner) (eval Evaluable, err error) {
+	return binaryOperatorParserHelper(tokenizer, ParseAsComparison, ParseAsComparison, parseAsEqualityMap)
+}
+
+var parseAsComparisonMap = map[string]binaryEvaluableCreator{
+	"<":  createComparisonExpression,
+	"<=": createComparisonExpression,
+	">":  createComparisonExpression,
+	">=": createComparisonExpression,
+}
+
+func ParseAsComparison(tokenizer *LookAheadScanner) (eval Evaluable, err error) {
+	return binaryOperatorParserHelper(tokenizer, ParseAsFactor1, ParseAsFactor1, parseAsComparisonMap)
 }
 
 var parseAsFactor1Map = map[string]binaryEvaluableCreator{
@@ -154,7 +212,30 @@ var parseAsFactor2Map = map[string]binaryEvaluableCreator{
 }
 
 func ParseAsFactor2(tokenizer *LookAheadScanner) (eval Evaluable, err error) {
-	return binaryOperatorParserHelper(tokenizer, ParseAsFactor3, ParseAsFactor2, parseAsFactor2Map)
+	return binaryOperatorParserHelper(tokenizer, ParseAsNot, ParseAsFactor2, parseAsFactor2Map)
+}
+
+func ParseAsNot(tokenizer *LookAheadScanner) (eval Evaluable, err error) {
+	if tokenizer.Text() != "!" {
+		return ParseAsFactor3(tokenizer)
+	}
+	if len(tokenizer.LookAheadBytes(1)) == 0 {
+		return nil, errUnmatched
+	}
+
+	tokenizer.Scan()
+	if e := tokenizer.Err(); e != nil {
+		return nil, e
+	}
+
+	exp, err := ParseAsNot(tokenizer)
+	if err == errUnmatched {
+		return nil, fmt.Errorf("syntax error: no expression is found after !: %s", tokenizer.Text())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &NotExpression{exp: exp}, nil
 }
 
 func ParseAsFactor3(tokenizer *LookAheadScanner) (eval Evaluable, err error) {
@@ -197,6 +278,10 @@ func ParseAsFactor3(tokenizer *LookAheadScanner) (eval Evaluable, err error) {
 	if err != errUnmatched {
 		return
 	}
+	eval, err = ParseAsBool(tokenizer)
+	if err != errUnmatched {
+		return
+	}
 	eval, err = ParseAsVariable(tokenizer)
 	if err != errUnmatched {
 		return
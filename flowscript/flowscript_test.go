@@ -23,3 +23,47 @@ func TestEvaluateScript(t *testing.T) {
 		}
 	}
 }
+
+func TestEvaluateScriptBool(t *testing.T) {
+	ge := createTestGlobalEnvironment()
+	testCases := []struct {
+		script   string
+		expected bool
+	}{
+		{"true", true},
+		{"false", false},
+		{"bar == 1", true},
+		{"bar != 1", false},
+		{"\"1\" == bar", true},
+		{"hoge == \"hoge\"", true},
+		{"hoge < \"hogf\"", true},
+		{"2 < 10", true},
+		{"\"2\" < \"10\"", false},
+		{"bar <= 1 && bar >= 1", true},
+		{"bar > 1 || foo == \"foo\"", true},
+		{"!(bar == 1)", false},
+		{"!false && true", true},
+		{"1 + 2 == 3", true},
+		{"true == 1", true},
+		{"false || 0", false},
+		{"false && undefinedVariable", false},
+		{"true || undefinedVariable", true},
+	}
+
+	for _, v := range testCases {
+		value, err := EvaluateScript(v.script, ge)
+		if err != nil {
+			t.Fatalf("error: %s: %s", v.script, err)
+		}
+		if b, ok := value.(BoolValue); !ok || b.Value() != v.expected {
+			t.Fatalf("bad result: %s: %s", v.script, value)
+		}
+	}
+
+	{
+		_, err := EvaluateScript("undefinedVariable && true", ge)
+		if err == nil {
+			t.Fatalf("error should be returned")
+		}
+	}
+}
@@ -13,6 +13,17 @@ var whiteSpaceRegexp = regexp.MustCompile("\\s")
 var digitRegexp = regexp.MustCompile("\\d")
 var wordCharacterRegexp = regexp.MustCompile("\\w")
 
+var twoCharacterOperators = map[string]struct{}{
+	"==": struct{}{},
+	"!=": struct{}{},
+	"<=": struct{}{},
+	">=": struct{}{},
+	"&&": struct{}{},
+	"||": struct{}{},
+}
+
+const twoCharacterOperatorHeads = "=!<>&|"
+
 func checkMatch(ch rune, exp *regexp.Regexp) bool {
 	var data [5]byte
 	var p = data[:]
@@ -94,6 +105,16 @@ func SplitToken(data []byte, atEOF bool) (advance int, token []byte, err error)
 			}
 		}
 	} else if l3 > 0 {
+		if len(data) > l3 {
+			if _, ok := twoCharacterOperators[string(data[:2])]; ok {
+				advance += 2
+				token = data[:2]
+				return
+			}
+		} else if !atEOF && strings.ContainsRune(twoCharacterOperatorHeads, firstChar) {
+			// wait for next character to check two character operators
+			return
+		}
 		advance += l3
 		token = data[:l3]
 		err = nil
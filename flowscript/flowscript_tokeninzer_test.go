@@ -78,6 +78,15 @@ func TestSplitFunc(t *testing.T) {
 	checkSplitResult(t, "123a", "123", 3, false)
 	checkSplitResult(t, "foo123;", "foo123", 6, false)
 	checkSplitResult(t, ";3", ";", 1, false)
+	checkSplitResult(t, "== 1", "==", 2, false)
+	checkSplitResult(t, "!=1", "!=", 2, false)
+	checkSplitResult(t, "<= 1", "<=", 2, false)
+	checkSplitResult(t, "&&a", "&&", 2, false)
+	checkSplitResult(t, "|| a", "||", 2, false)
+	checkSplitResult(t, "< 1", "<", 1, false)
+	checkSplitResult(t, "!a", "!", 1, false)
+	checkSplitResult(t, "=", "", 0, false)
+	checkSplitResult(t, "=", "=", 1, true)
 
 	checkSplitResult(t, "123", "", 0, false)
 	checkSplitResult(t, "abc123", "", 0, false)
@@ -147,3 +156,23 @@ func TestScanner2(t *testing.T) {
 		t.Fatalf("Should reached to EOF: %s (%d)", scanner.Bytes(), len(scanner.Bytes()))
 	}
 }
+
+func TestScannerOperators(t *testing.T) {
+	expectedTokens := [...]string{"a", "==", "1", "&&", "!", "(", "b", "<", "2", "||", "c", ">=", "3", ")", "!=", "true"}
+
+	reader := strings.NewReader("a==1 && !(b < 2||c>=3) != true")
+	scanner := NewTokenizer(reader)
+
+	for _, v := range expectedTokens {
+		if s := scanner.Scan(); !s {
+			t.Fatal("Failed to scan")
+		}
+		if x := scanner.Text(); x != v {
+			t.Fatalf("Bad token: %s / expected: %s", x, v)
+		}
+	}
+
+	if scanner.Scan() {
+		t.Fatalf("Should reached to EOF: %s (%d)", scanner.Bytes(), len(scanner.Bytes()))
+	}
+}
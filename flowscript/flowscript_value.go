@@ -14,6 +14,39 @@ type Value interface {
 	AsString() (string, error)
 	// Convert to int
 	AsInt() (int64, error)
+	// Convert to bool to use as condition
+	AsBool() (bool, error)
+}
+
+type BoolValue struct {
+	value bool
+}
+
+func NewBoolValue(val bool) BoolValue {
+	return BoolValue{val}
+}
+
+func (v BoolValue) Value() bool {
+	return v.value
+}
+
+func (v BoolValue) String() string {
+	return strconv.FormatBool(v.value)
+}
+
+func (v BoolValue) AsString() (string, error) {
+	return strconv.FormatBool(v.value), nil
+}
+
+func (v BoolValue) AsInt() (int64, error) {
+	if v.value {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (v BoolValue) AsBool() (bool, error) {
+	return v.value, nil
 }
 
 type IntValue struct {
@@ -40,6 +73,10 @@ func (v IntValue) AsInt() (int64, error) {
 	return v.value, nil
 }
 
+func (v IntValue) AsBool() (bool, error) {
+	return v.value != 0, nil
+}
+
 type StringValue struct {
 	value string
 }
@@ -66,6 +103,11 @@ func (v StringValue) AsInt() (int64, error) {
 	return strconv.ParseInt(v.value, 10, 64)
 }
 
+// AsBool returns false for an empty string, "0" and "false"
+func (v StringValue) AsBool() (bool, error) {
+	return v.value != "" && v.value != "0" && strings.ToLower(v.value) != "false", nil
+}
+
 type ArrayValue struct {
 	value []Value
 }
@@ -127,6 +169,10 @@ func (v ArrayValue) AsInt() (int64, error) {
 	return 0, errors.New("Cannot convert array to int")
 }
 
+func (v ArrayValue) AsBool() (bool, error) {
+	return len(v.value) > 0, nil
+}
+
 type MapValue struct {
 	value map[string]Value
 }
@@ -175,6 +221,10 @@ func (v MapValue) AsInt() (int64, error) {
 	return 0, errors.New("Cannot convert map to int")
 }
 
+func (v MapValue) AsBool() (bool, error) {
+	return len(v.value) > 0, nil
+}
+
 type FunctionValue struct {
 	value *ScriptFunction
 }
@@ -194,3 +244,7 @@ func (v FunctionValue) AsString() (string, error) {
 func (v FunctionValue) AsInt() (int64, error) {
 	return 0, errors.New("Cannot convert function to int")
 }
+
+func (v FunctionValue) AsBool() (bool, error) {
+	return false, errors.New("Cannot convert function to bool")
+}
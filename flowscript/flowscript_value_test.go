@@ -152,4 +152,51 @@ func TestValueStringAndType(t *testing.T) {
 			t.Fatalf("Invalid value: %s", v.Value())
 		}
 	}
+	{
+		var boolValue Value = NewBoolValue(true)
+		if r, e := boolValue.AsString(); e != nil || r != "true" {
+			t.Fatalf("Invalid bool: %s / error:%s", r, e)
+		}
+		if r, e := boolValue.AsInt(); e != nil || r != 1 {
+			t.Fatalf("Invalid bool: %d / error:%s", r, e)
+		}
+		if r, e := boolValue.AsBool(); e != nil || !r {
+			t.Fatalf("Invalid bool: %v / error:%s", r, e)
+		}
+		if r := boolValue.String(); r != "true" {
+			t.Fatalf("Invalid bool: %s", r)
+		}
+		if v, ok := boolValue.(BoolValue); !ok || !v.Value() {
+			t.Fatalf("Invalid value: %v", v.Value())
+		}
+	}
+}
+
+func TestValueAsBool(t *testing.T) {
+	testCases := []struct {
+		value    Value
+		expected bool
+	}{
+		{BoolValue{false}, false},
+		{IntValue{0}, false},
+		{IntValue{2}, true},
+		{StringValue{""}, false},
+		{StringValue{"0"}, false},
+		{StringValue{"False"}, false},
+		{StringValue{"no"}, true},
+		{ArrayValue{[]Value{}}, false},
+		{ArrayValue{[]Value{IntValue{1}}}, true},
+		{MapValue{map[string]Value{}}, false},
+		{MapValue{map[string]Value{"a": IntValue{1}}}, true},
+	}
+
+	for _, v := range testCases {
+		if r, e := v.value.AsBool(); e != nil || r != v.expected {
+			t.Fatalf("Invalid bool value of %s: %v / error:%s", v.value, r, e)
+		}
+	}
+
+	if r, e := (FunctionValue{BuiltinFunctions["basename"]}).AsBool(); e == nil {
+		t.Fatalf("Invalid bool value of function: %v", r)
+	}
 }
@@ -4,9 +4,9 @@ package main
 
 import "strconv"
 
-const _JobState_name = "JobDoneJobRunningJobFailedJobPendingJobUnknown"
+const _JobState_name = "JobDoneJobRunningJobFailedJobPendingJobUnknownJobTimeoutJobCancelled"
 
-var _JobState_index = [...]uint8{0, 7, 17, 26, 36, 46}
+var _JobState_index = [...]uint8{0, 7, 17, 26, 36, 46, 56, 68}
 
 func (i JobState) String() string {
 	if i < 0 || i >= JobState(len(_JobState_index)-1) {
@@ -7,6 +7,7 @@ import (
 	"io"
 	"os"
 	"path"
+	"strconv"
 	"strings"
 
 	"bufio"
@@ -30,10 +31,18 @@ func main() {
 		err = runMode()
 	case "dot":
 		err = dotMode()
+	case "explain":
+		err = explainMode()
 	case "filelog":
 		err = fileLogMode()
 	case "viewlog":
 		err = viewLogMode()
+	case "resume":
+		err = resumeMode()
+	case "cancel":
+		err = cancelMode()
+	case "config":
+		err = configMode()
 	case "-h", "-?", "help":
 		helpMode(os.Args[2:])
 	default:
@@ -51,7 +60,11 @@ func helpMode(args []string) error {
 
 Commands:
   run         Run workflow
+  resume      Run pending or failed tasks of a workflow again in the same log directory
+  cancel      Cancel running jobs of a workflow
+  config      Show effective configuration ("config show")
   dot         Export workflow as dot language for visualization
+  explain     Show why each task will run or be reused
   flowscript  Launch flowscript interpreter
   viewlog     Show execution log
   filelog     Create a file log file, which contains SHA256 hash, modification date and so on
@@ -64,12 +77,17 @@ func viewLogMode() error {
 	f := flag.NewFlagSet("shellflow filelog", flag.ExitOnError)
 	var showAll bool
 	var failedOnly bool
+	var resource bool
 	f.BoolVar(&showAll, "all", false, "Show All")
 	f.BoolVar(&failedOnly, "failed", false, "Show Failed Job Only")
+	f.BoolVar(&resource, "resource", false, "Show resource usage of jobs")
+	addContentHashFlag(f)
 	f.Parse(os.Args[2:])
 
 	var err error
-	if len(f.Args()) > 0 {
+	if len(f.Args()) > 0 && resource {
+		err = ViewResourceUsage(f.Args())
+	} else if len(f.Args()) > 0 {
 		err = ViewLogDetail(f.Args(), failedOnly)
 	} else {
 		err = ViewLog(showAll, failedOnly)
@@ -138,7 +156,11 @@ func dotMode() error {
 
 	f := flag.NewFlagSet("shellflow dot", flag.ExitOnError)
 	f.StringVar(&paramFile, "param", "", "Parameter File")
+	addConfigFlag(f)
 	f.Parse(os.Args[2:])
+	if err := resolveConfigFlag(); err != nil {
+		return err
+	}
 
 	if len(f.Args()) != 1 {
 		helpMode([]string{"dot"})
@@ -160,10 +182,55 @@ func dotMode() error {
 	return nil
 }
 
+func explainMode() error {
+	paramFile := ""
+	outputJSON := false
+
+	f := flag.NewFlagSet("shellflow explain", flag.ExitOnError)
+	f.StringVar(&paramFile, "param", "", "Parameter File")
+	f.BoolVar(&outputJSON, "json", false, "Output as JSON")
+	addContentHashFlag(f)
+	selection := addTaskSelectionFlags(f)
+	addConfigFlag(f)
+	f.Parse(os.Args[2:])
+	if err := resolveConfigFlag(); err != nil {
+		return err
+	}
+
+	if len(f.Args()) != 1 {
+		helpMode([]string{"explain"})
+		return fmt.Errorf("No workflow file")
+	}
+
+	env := NewEnvironment()
+	parameters, err := loadParameter(paramFile)
+	if err != nil {
+		return err
+	}
+
+	builder, err := parse(env, f.Args()[0], parameters)
+	if err != nil {
+		return err
+	}
+
+	err = selection.apply(builder)
+	if err != nil {
+		return err
+	}
+
+	explanations := ExplainTasks(builder)
+	if outputJSON {
+		return WriteExplanationJSON(os.Stdout, explanations)
+	}
+	return WriteExplanationTable(os.Stdout, explanations)
+}
+
 func runMode() error {
 	f := flag.NewFlagSet("shellflow run", flag.ExitOnError)
 
 	useSge := false
+	useSlurm := false
+	backendType := ""
 	paramFile := ""
 
 	env := NewEnvironment()
@@ -171,15 +238,29 @@ func runMode() error {
 	f.BoolVar(&env.dryRun, "dry-run", false, "Print jobs to run without execute")
 	f.BoolVar(&env.scriptsOnly, "scripts-only", false, "Generate scripts only")
 	f.BoolVar(&env.rerunAll, "rerun", false, "Rerun all commands even if contents are not changed")
+	addContentHashFlag(f)
 	f.BoolVar(&useSge, "sge", false, "Use SGE/UGE instead of local executer")
+	f.BoolVar(&useSlurm, "slurm", false, "Use Slurm instead of local executer")
+	f.StringVar(&backendType, "backend", "", "Backend type (default: [Backend] Type in configuration or local)")
+	f.IntVar(&env.localJobs, "local-jobs", 1, "Number of jobs to run concurrently with local executer")
 	f.StringVar(&paramFile, "param", "", "Parameter File")
+	selection := addTaskSelectionFlags(f)
+	addConfigFlag(f)
 	f.Parse(os.Args[2:])
+	if err := resolveConfigFlag(); err != nil {
+		return err
+	}
 
 	if len(f.Args()) == 0 {
 		helpMode([]string{"run"})
 		return fmt.Errorf("No workflow file")
 	}
 
+	executer, err := selectExecuter(useSge, useSlurm, backendType)
+	if err != nil {
+		return err
+	}
+
 	parameters, err := loadParameter(paramFile)
 	if err != nil {
 		return err
@@ -191,9 +272,17 @@ func runMode() error {
 	}
 	//fmt.Printf("%s\n", f.Args())
 
+	err = selection.apply(builder)
+	if err != nil {
+		return err
+	}
+
 	if env.dryRun {
 		for _, v := range builder.Tasks {
 			if !v.ShouldSkip || env.rerunAll {
+				if reasons := builder.RerunReasons(v.ID); len(reasons) > 0 && !env.rerunAll {
+					fmt.Printf("# not reused: %s\n", strings.Join(reasons, ", "))
+				}
 				fmt.Printf("%s\n", v.ShellScript)
 			}
 		}
@@ -212,33 +301,209 @@ func runMode() error {
 	}
 
 	if !env.scriptsOnly {
-		if useSge {
-			err = ExecuteInSge(gen)
-		} else {
-			err = ExecuteLocalSingle(gen)
-		}
+		return submit(executer, gen)
+	}
+	return nil
+}
+
+func resumeMode() error {
+	f := flag.NewFlagSet("shellflow resume", flag.ExitOnError)
+
+	useSge := false
+	useSlurm := false
+	backendType := ""
+
+	env := NewEnvironment()
+	f.BoolVar(&useSge, "sge", false, "Use SGE/UGE instead of local executer")
+	f.BoolVar(&useSlurm, "slurm", false, "Use Slurm instead of local executer")
+	f.StringVar(&backendType, "backend", "", "Backend type (default: [Backend] Type in configuration or local)")
+	f.IntVar(&env.localJobs, "local-jobs", 1, "Number of jobs to run concurrently with local executer")
+	addContentHashFlag(f)
+	addConfigFlag(f)
+	f.Parse(os.Args[2:])
+	if err := resolveConfigFlag(); err != nil {
+		return err
+	}
+
+	if len(f.Args()) != 1 {
+		helpMode([]string{"resume"})
+		return fmt.Errorf("No workflow log number or directory")
+	}
+
+	executer, err := selectExecuter(useSge, useSlurm, backendType)
+	if err != nil {
+		return err
+	}
+
+	workflowLogRoot, err := FindWorkflowLogRoot(f.Args()[0])
+	if err != nil {
+		return err
+	}
+
+	gen, err := PrepareResume(workflowLogRoot, env)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Workflow Log: %s\n", workflowLogRoot)
+
+	return submit(executer, gen)
+}
+
+func cancelMode() error {
+	f := flag.NewFlagSet("shellflow cancel", flag.ExitOnError)
+	f.Parse(os.Args[2:])
+
+	if len(f.Args()) != 1 {
+		helpMode([]string{"cancel"})
+		return fmt.Errorf("No workflow log number or directory")
+	}
+
+	workflowLogRoot, err := FindWorkflowLogRoot(f.Args()[0])
+	if err != nil {
+		return err
+	}
+
+	cancelled, err := CancelWorkflow(workflowLogRoot)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d jobs are cancelled\n", cancelled)
+	return nil
+}
+
+// stringListFlag is a flag which can be given multiple times
+type stringListFlag []string
+
+func (v *stringListFlag) String() string {
+	return strings.Join(*v, ",")
+}
+
+func (v *stringListFlag) Set(value string) error {
+	*v = append(*v, value)
+	return nil
+}
+
+// intListFlag is a flag of numbers which can be given multiple times
+type intListFlag []int
+
+func (v *intListFlag) String() string {
+	return fmt.Sprint([]int(*v))
+}
+
+func (v *intListFlag) Set(value string) error {
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("Invalid line number: %s", value)
+	}
+	*v = append(*v, i)
+	return nil
+}
+
+func configMode() error {
+	f := flag.NewFlagSet("shellflow config", flag.ExitOnError)
+	addConfigFlag(f)
+	f.Parse(os.Args[2:])
+	if err := resolveConfigFlag(); err != nil {
+		return err
+	}
+
+	if len(f.Args()) != 1 || f.Args()[0] != "show" {
+		helpMode([]string{"config"})
+		return fmt.Errorf("Unknown config command")
+	}
+	return ShowConfiguration(os.Stdout)
+}
+
+// taskSelectionFlags are options to select tasks to run, shared by run and
+// explain commands
+type taskSelectionFlags struct {
+	targetFiles stringListFlag
+	untilLines  intListFlag
+	fromLines   intListFlag
+	rerunTasks  stringListFlag
+}
+
+func addTaskSelectionFlags(f *flag.FlagSet) *taskSelectionFlags {
+	v := &taskSelectionFlags{}
+	f.Var(&v.targetFiles, "target", "Run only tasks required to create the file (can be repeated)")
+	f.Var(&v.untilLines, "until", "Run only tasks required to run a task at the line (can be repeated)")
+	f.Var(&v.fromLines, "from", "Rerun tasks at the line and all tasks depending on them (can be repeated)")
+	f.Var(&v.rerunTasks, "rerun-tasks", "Rerun tasks matched to id:N, line:N, regexp:PATTERN or file:GLOB and all tasks depending on them (can be repeated)")
+	return v
+}
+
+// apply marks tasks to rerun, and then removes tasks which are not selected
+func (v *taskSelectionFlags) apply(builder *ShellTaskBuilder) error {
+	err := ForceRerunFrom(builder, v.fromLines)
+	if err != nil {
+		return err
+	}
+	err = ForceRerunTasks(builder, v.rerunTasks)
+	if err != nil {
+		return err
+	}
+	return SelectTargetTasks(builder, v.targetFiles, v.untilLines)
+}
+
+func addContentHashFlag(f *flag.FlagSet) {
+	f.BoolVar(&CompareContentHash, "content-hash", false, "Regard a file as unchanged if SHA256 is not changed even if modification date is changed")
+}
+
+func addConfigFlag(f *flag.FlagSet) {
+	f.StringVar(&ExtraConfig, "config", "", "Configuration file which overrides other configuration files")
+}
+
+// resolveConfigFlag converts a path given with -config option into absolute
+// path, because working directory is changed while a workflow is processed.
+func resolveConfigFlag() error {
+	if ExtraConfig == "" {
+		return nil
+	}
+	if _, err := os.Stat(ExtraConfig); err != nil {
+		return fmt.Errorf("Cannot open configuration file: %s", err.Error())
+	}
+	ExtraConfig = Abs(ExtraConfig)
+	return nil
+}
 
+func selectExecuter(useSge bool, useSlurm bool, backendType string) (Executer, error) {
+	if useSge {
+		backendType = "sge"
+	} else if useSlurm {
+		backendType = "slurm"
+	} else if backendType == "" {
+		conf, err := LoadConfiguration()
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
-			exeErr, ok := err.(*executationError)
-			if ok {
-				jobLog, err := CollectLogsForOneJob(exeErr.jobRoot, exeErr.shellTask)
-				var errReader io.ReadCloser
-				if jobLog.ScriptExitCode == 0 {
-					errReader, err = os.Open(path.Join(exeErr.jobRoot, "run.stderr"))
-				} else {
-					errReader, err = os.Open(path.Join(exeErr.jobRoot, "script.stderr"))
-				}
-				if err != nil {
-					return err
-				}
-				defer errReader.Close()
-				io.Copy(os.Stderr, errReader)
+			return nil, err
+		}
+		backendType = conf.Backend.Type
+	}
 
-				os.Exit(1)
+	return GetExecuter(backendType)
+}
+
+func submit(executer Executer, gen *TaskScripts) error {
+	err := executer.Submit(gen)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+		exeErr, ok := err.(*executationError)
+		if ok {
+			jobLog, err := CollectLogsForOneJob(exeErr.jobRoot, exeErr.shellTask)
+			var errReader io.ReadCloser
+			if jobLog.ScriptExitCode == 0 {
+				errReader, err = os.Open(path.Join(exeErr.jobRoot, "run.stderr"))
 			} else {
+				errReader, err = os.Open(path.Join(exeErr.jobRoot, "script.stderr"))
+			}
+			if err != nil {
 				return err
 			}
+			defer errReader.Close()
+			io.Copy(os.Stderr, errReader)
+
+			os.Exit(1)
+		} else {
+			return err
 		}
 	}
 	return nil
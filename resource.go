@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"runtime"
+	"syscall"
+	"time"
+)
+
+const resourceUsageFileName = "resource.json"
+
+// resourceUsageUnavailableFileName is written when a job scheduler does not
+// have accounting data of a job
+const resourceUsageUnavailableFileName = "resource-unavailable.txt"
+
+// resourceUsageRetryPeriod is a period after a job is finished while a job
+// scheduler is asked again for accounting data which is not available yet
+var resourceUsageRetryPeriod = 10 * time.Minute
+
+// ResourceUsage is resource usage of a job. Times are recorded in seconds.
+type ResourceUsage struct {
+	WallTime   float64
+	UserTime   float64
+	SystemTime float64
+	MaxRSS     Memory
+	MaxVMem    Memory
+}
+
+func (v *ResourceUsage) String() string {
+	return fmt.Sprintf("Wall: %s / User: %s / System: %s / MaxRSS: %s", formatSeconds(v.WallTime), formatSeconds(v.UserTime), formatSeconds(v.SystemTime), v.MaxRSS.String())
+}
+
+func formatSeconds(seconds float64) string {
+	return time.Duration(seconds * float64(time.Second)).Round(time.Millisecond).String()
+}
+
+// resourceUsageCollector is implemented by executers which can collect
+// resource usage of finished jobs from a job scheduler.
+type resourceUsageCollector interface {
+	CollectResourceUsage(jobLogRoot string) (*ResourceUsage, error)
+}
+
+// NewResourceUsageFromProcessState creates resource usage of a finished local process
+func NewResourceUsageFromProcessState(state *os.ProcessState, wallTime time.Duration) *ResourceUsage {
+	usage := &ResourceUsage{
+		WallTime:   wallTime.Seconds(),
+		UserTime:   state.UserTime().Seconds(),
+		SystemTime: state.SystemTime().Seconds(),
+	}
+	if rusage, ok := state.SysUsage().(*syscall.Rusage); ok {
+		// ru_maxrss is bytes in macOS and kilobytes in other systems
+		if runtime.GOOS == "darwin" {
+			usage.MaxRSS = Memory{int64(rusage.Maxrss)}
+		} else {
+			usage.MaxRSS = Memory{int64(rusage.Maxrss) * 1024}
+		}
+	}
+	return usage
+}
+
+func WriteResourceUsage(jobLogRoot string, usage *ResourceUsage) error {
+	data, err := json.MarshalIndent(usage, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path.Join(jobLogRoot, resourceUsageFileName), data, 0644)
+}
+
+// LoadResourceUsage loads recorded resource usage of a job.
+// nil is returned if resource usage is not recorded.
+func LoadResourceUsage(jobLogRoot string) (*ResourceUsage, error) {
+	var usage ResourceUsage
+	err := LoadJsonFromFile(path.Join(jobLogRoot, resourceUsageFileName), &usage)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &usage, nil
+}
+
+// CollectResourceUsage loads resource usage of a finished job. If resource
+// usage is not recorded yet, a job scheduler which ran the job is asked.
+// Accounting data may be written a while after a job is finished, so a
+// marker file to avoid asking again is written only if accounting data is
+// not available after resourceUsageRetryPeriod.
+// nil is returned if resource usage is not available.
+func CollectResourceUsage(jobLogRoot string) (*ResourceUsage, error) {
+	usage, err := LoadResourceUsage(jobLogRoot)
+	if err != nil || usage != nil {
+		return usage, err
+	}
+
+	markerPath := path.Join(jobLogRoot, resourceUsageUnavailableFileName)
+	if _, err := os.Stat(markerPath); err == nil {
+		return nil, nil
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	name, _, err := readSchedulerJobID(jobLogRoot)
+	if err != nil {
+		return nil, err
+	}
+	collector, ok := executers[name].(resourceUsageCollector)
+	if !ok {
+		return nil, nil
+	}
+	usage, err = collector.CollectResourceUsage(jobLogRoot)
+	if err != nil {
+		return nil, err
+	}
+	if usage == nil {
+		rcStat, err := os.Stat(path.Join(jobLogRoot, "rc"))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if err == nil && time.Since(rcStat.ModTime()) < resourceUsageRetryPeriod {
+			return nil, nil
+		}
+		return nil, ioutil.WriteFile(markerPath, []byte{}, 0644)
+	}
+	return usage, WriteResourceUsage(jobLogRoot, usage)
+}
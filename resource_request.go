@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/informationsea/shellflow/flowscript"
+)
+
+// ResourceTemplate is a set of job scheduler options used to request
+// resources. Each option can contain flowscript like "{{memory_mb}}".
+// Options are only used when the resource is requested.
+type ResourceTemplate struct {
+	Memory   []string
+	CPU      []string
+	Walltime []string
+	GPU      []string
+}
+
+// defaultResourceTemplates are used when no template is configured for a backend
+var defaultResourceTemplates = map[string]ResourceTemplate{
+	"sge": ResourceTemplate{
+		Memory:   []string{"-l", "s_vmem={{memory}},mem_req={{memory}}"},
+		CPU:      []string{"-pe", "def_slot", "{{cpu}}"},
+		Walltime: []string{"-l", "h_rt={{walltime_seconds}}"},
+		GPU:      []string{"-l", "gpu={{gpu}}"},
+	},
+	"slurm": ResourceTemplate{
+		Memory:   []string{"--mem={{memory_mb}}M"},
+		CPU:      []string{"--cpus-per-task={{cpu}}"},
+		Walltime: []string{"--time={{walltime}}"},
+		GPU:      []string{"--gres=gpu:{{gpu}}"},
+	},
+	"pbs": ResourceTemplate{
+		Memory:   []string{"-l", "mem={{memory_mb}}mb"},
+		CPU:      []string{"-l", "ncpus={{cpu}}"},
+		Walltime: []string{"-l", "walltime={{walltime}}"},
+		GPU:      []string{"-l", "ngpus={{gpu}}"},
+	},
+	"lsf": ResourceTemplate{
+		Memory:   []string{"-R", "rusage[mem={{memory_mb}}]"},
+		CPU:      []string{"-n", "{{cpu}}", "-R", "span[hosts=1]"},
+		Walltime: []string{"-W", "{{walltime_minutes}}"},
+		GPU:      []string{"-gpu", "num={{gpu}}"},
+	},
+	"condor": ResourceTemplate{
+		Memory:   []string{"request_memory = {{memory_mb}}"},
+		CPU:      []string{"request_cpus = {{cpu}}"},
+		Walltime: []string{"allowed_execute_duration = {{walltime_seconds}}"},
+		GPU:      []string{"request_gpus = {{gpu}}"},
+	},
+}
+
+// LoadResourceTemplate loads a resource template of a backend. Templates in
+// configuration file override default templates.
+func LoadResourceTemplate(backend string) (*ResourceTemplate, error) {
+	conf, err := LoadConfiguration()
+	if err != nil {
+		return nil, err
+	}
+	template := defaultResourceTemplates[backend]
+	if configured, ok := conf.ResourceTemplate[backend]; ok {
+		if configured.Memory != nil {
+			template.Memory = configured.Memory
+		}
+		if configured.CPU != nil {
+			template.CPU = configured.CPU
+		}
+		if configured.Walltime != nil {
+			template.Walltime = configured.Walltime
+		}
+		if configured.GPU != nil {
+			template.GPU = configured.GPU
+		}
+	}
+	return &template, nil
+}
+
+// Options creates job scheduler options from requested resources of a command.
+func (t *ResourceTemplate) Options(conf *CommandConfiguration) ([]string, error) {
+	memory, err := conf.MemoryRequest()
+	if err != nil {
+		return nil, err
+	}
+	walltime, err := conf.WalltimeDuration()
+	if err != nil {
+		return nil, err
+	}
+
+	mib := float64(1024 * 1024)
+	vars := map[string]flowscript.Value{
+		"memory":           flowscript.NewStringValue(strings.TrimSpace(conf.Memory)),
+		"memory_kb":        flowscript.NewIntValue(int64(math.Ceil(float64(memory.Byte()) / 1024))),
+		"memory_mb":        flowscript.NewIntValue(int64(math.Ceil(float64(memory.Byte()) / mib))),
+		"memory_gb":        flowscript.NewIntValue(int64(math.Ceil(float64(memory.Byte()) / mib / 1024))),
+		"cpu":              flowscript.NewIntValue(int64(conf.CPU)),
+		"gpu":              flowscript.NewIntValue(int64(conf.GPU)),
+		"walltime":         flowscript.NewStringValue(formatWalltime(walltime)),
+		"walltime_seconds": flowscript.NewIntValue(int64(math.Ceil(walltime.Seconds()))),
+		"walltime_minutes": flowscript.NewIntValue(int64(math.Ceil(walltime.Minutes()))),
+	}
+	env := flowscript.CreateMixedEnvironment(flowscript.NewGlobalEnvironment(), vars)
+
+	options := make([]string, 0)
+	for _, v := range []struct {
+		requested bool
+		template  []string
+	}{
+		{memory.Byte() > 0, t.Memory},
+		{conf.CPU > 0, t.CPU},
+		{walltime > 0, t.Walltime},
+		{conf.GPU > 0, t.GPU},
+	} {
+		if !v.requested {
+			continue
+		}
+		for _, x := range v.template {
+			task, err := NewSingleShellTask(0, x)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid resource template %s: %s", x, err.Error())
+			}
+			option, err := task.EvaluatedShell(env)
+			if err != nil {
+				return nil, fmt.Errorf("Cannot evaluate resource template %s: %s", x, err.Error())
+			}
+			options = append(options, option)
+		}
+	}
+	return options, nil
+}
+
+// formatWalltime formats duration as HH:MM:SS
+func formatWalltime(d time.Duration) string {
+	seconds := int64(math.Ceil(d.Seconds()))
+	return fmt.Sprintf("%02d:%02d:%02d", seconds/3600, (seconds/60)%60, seconds%60)
+}
+
+// LocalResourceBudget is amount of resources which can be used by local executer at once.
+// Zero memory means no limit.
+type LocalResourceBudget struct {
+	CPU    int
+	Memory Memory
+}
+
+// LoadLocalResourceBudget loads [Local] section of configuration. Number of
+// CPUs and size of physical memory are used if they are not configured.
+func LoadLocalResourceBudget() (*LocalResourceBudget, error) {
+	conf, err := LoadConfiguration()
+	if err != nil {
+		return nil, err
+	}
+
+	budget := &LocalResourceBudget{CPU: conf.Local.CPU}
+	if budget.CPU <= 0 {
+		budget.CPU = runtime.NumCPU()
+	}
+	if conf.Local.Memory != "" {
+		budget.Memory, err = NewMemory(conf.Local.Memory)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid memory in [Local] section: %s", err.Error())
+		}
+	} else {
+		budget.Memory = physicalMemory()
+	}
+	return budget, nil
+}
+
+// physicalMemory reads total memory from /proc/meminfo. Zero is returned if
+// it is not available.
+func physicalMemory() Memory {
+	data, err := ioutil.ReadFile("/proc/meminfo")
+	if err != nil {
+		return Memory{}
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "MemTotal:" {
+			kb, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return Memory{}
+			}
+			return Memory{kb * 1024}
+		}
+	}
+	return Memory{}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestResourceTemplateOptions(t *testing.T) {
+	conf := CommandConfiguration{
+		Memory:   "20G",
+		CPU:      4,
+		Walltime: "1h30m",
+		GPU:      1,
+	}
+
+	expected := map[string][]string{
+		"sge":    []string{"-l", "s_vmem=20G,mem_req=20G", "-pe", "def_slot", "4", "-l", "h_rt=5400", "-l", "gpu=1"},
+		"slurm":  []string{"--mem=20480M", "--cpus-per-task=4", "--time=01:30:00", "--gres=gpu:1"},
+		"pbs":    []string{"-l", "mem=20480mb", "-l", "ncpus=4", "-l", "walltime=01:30:00", "-l", "ngpus=1"},
+		"lsf":    []string{"-R", "rusage[mem=20480]", "-n", "4", "-R", "span[hosts=1]", "-W", "90", "-gpu", "num=1"},
+		"condor": []string{"request_memory = 20480", "request_cpus = 4", "allowed_execute_duration = 5400", "request_gpus = 1"},
+	}
+
+	for k, v := range expected {
+		template := defaultResourceTemplates[k]
+		options, err := template.Options(&conf)
+		if err != nil {
+			t.Fatalf("error: %s", err.Error())
+		}
+		if !reflect.DeepEqual(options, v) {
+			t.Fatalf("bad %s options: %s", k, options)
+		}
+	}
+
+	// only requested resources are used
+	template := defaultResourceTemplates["slurm"]
+	options, err := template.Options(&CommandConfiguration{CPU: 2})
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	if !reflect.DeepEqual(options, []string{"--cpus-per-task=2"}) {
+		t.Fatalf("bad options: %s", options)
+	}
+
+	// flowscript can be used in templates
+	template = ResourceTemplate{Memory: []string{"-l", "mem={{memory_gb * cpu}}G"}}
+	options, err = template.Options(&CommandConfiguration{Memory: "1.5G", CPU: 3})
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	if !reflect.DeepEqual(options, []string{"-l", "mem=6G"}) {
+		t.Fatalf("bad options: %s", options)
+	}
+
+	template = ResourceTemplate{CPU: []string{"{{unknown}}"}}
+	if _, err := template.Options(&CommandConfiguration{CPU: 3}); err == nil {
+		t.Fatalf("unknown variable should be error")
+	}
+}
+
+func TestCommandConfigurationValidate(t *testing.T) {
+	if err := (&CommandConfiguration{Memory: "10G", Walltime: "2h", CPU: 1}).Validate(); err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	for _, v := range []CommandConfiguration{
+		CommandConfiguration{Memory: "10X"},
+		CommandConfiguration{Walltime: "2 hours"},
+		CommandConfiguration{Walltime: "-1h"},
+		CommandConfiguration{CPU: -1},
+		CommandConfiguration{GPU: -1},
+		CommandConfiguration{Timeout: "x"},
+	} {
+		if err := v.Validate(); err == nil {
+			t.Fatalf("invalid configuration should be error: %s", v.String())
+		}
+	}
+}
+
+func TestFormatWalltime(t *testing.T) {
+	if v := formatWalltime(26*time.Hour + 3*time.Minute + 1500*time.Millisecond); v != "26:03:02" {
+		t.Fatalf("bad walltime: %s", v)
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+
+	"github.com/informationsea/shellflow/flowscript"
+)
+
+// resumeArchivedFiles are moved to resumeN directory before a job is executed again
+var resumeArchivedFiles = []string{
+	"rc", "script.stdout", "script.stderr", "run.stdout", "run.stderr",
+	"input.json", "output.json", localRunPidFile, sgeTaskIDFileName,
+	"sge-submit-args.txt", slurmJobIDFileName, "slurm-submit-args.txt", jobReasonFileName,
+	resourceUsageFileName, pbsJobIDFileName, "pbs-submit-args.txt",
+	lsfJobIDFileName, "lsf-submit-args.txt", condorNodeFileName, containerImageFileName,
+	condaEnvironmentFileName, modulesFileName,
+}
+
+// FindWorkflowLogRoot returns a workflow log directory from a log number shown
+// in viewlog or a path to a directory.
+func FindWorkflowLogRoot(arg string) (string, error) {
+	if num, err := strconv.ParseInt(arg, 10, 32); err == nil {
+		logs, err := CollectLogs(WorkflowLogDir)
+		if err != nil {
+			return "", err
+		}
+		if num <= 0 || num > int64(len(logs)) {
+			return "", fmt.Errorf("Bad Workflow Record Number: %s", arg)
+		}
+		return logs[num-1].WorkflowLogRoot, nil
+	}
+
+	stat, err := os.Stat(arg)
+	if err != nil {
+		return "", fmt.Errorf("Cannot open workflow log directory: %s", err.Error())
+	}
+	if !stat.IsDir() {
+		return "", fmt.Errorf("%s is not a directory", arg)
+	}
+	return arg, nil
+}
+
+// PrepareResume loads a workflow log directory and prepares to run pending
+// or failed tasks again in the same directory. Job logs of tasks to run are
+// archived in resumeN directory. An error is returned if any job is still
+// running.
+func PrepareResume(workflowLogRoot string, env *Environment) (*TaskScripts, error) {
+	workflowLogRoot = Abs(workflowLogRoot)
+
+	var metadata WorkflowMetaData
+	err := LoadJsonFromFile(path.Join(workflowLogRoot, "runtime.json"), &metadata)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot load runtime information: %s", err.Error())
+	}
+
+	builder := &ShellTaskBuilder{
+		CurrentID:           len(metadata.Tasks),
+		Tasks:               metadata.Tasks,
+		MissingCreatorFiles: flowscript.NewStringSet(),
+		WorkflowContent:     metadata.Workflow,
+	}
+
+	env.workDir = metadata.WorkDir
+	env.parameters = metadata.Parameters
+
+	jobName := path.Base(metadata.WorkflowPath)
+	if metadata.ParameterFile != "" {
+		jobName += " " + path.Base(metadata.ParameterFile)
+	}
+
+	ret := TaskScripts{
+		workflowRoot: workflowLogRoot,
+		jobName:      jobName,
+		scripts:      make(map[int]*GeneratedScript),
+		env:          env,
+		builder:      builder,
+	}
+
+	jobLogs := make([]*JobLog, len(builder.Tasks))
+	for i, v := range builder.Tasks {
+		jobRoot := path.Join(workflowLogRoot, fmt.Sprintf("job%03d", v.ID))
+		jobLogs[i], err = CollectLogsForOneJob(jobRoot, v)
+		if err != nil {
+			return nil, err
+		}
+
+		if !jobLogs[i].IsDone {
+			state, err := JobStatus(jobRoot)
+			if err != nil {
+				return nil, err
+			}
+			if state == JobRunning {
+				return nil, fmt.Errorf("Job %d is still running: %s", v.ID, jobRoot)
+			}
+		}
+	}
+
+	for i, v := range builder.Tasks {
+		jobRoot := jobLogs[i].JobLogRoot
+		v.ShouldSkip = jobLogs[i].State() == JobDone
+		if !v.ShouldSkip {
+			err = archiveJobLog(jobRoot)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		ret.scripts[v.ID] = &GeneratedScript{
+			JobRoot:       jobRoot,
+			StdoutPath:    path.Join(jobRoot, "script.stdout"),
+			StderrPath:    path.Join(jobRoot, "script.stderr"),
+			ScriptPath:    path.Join(jobRoot, "script.sh"),
+			RunScriptPath: path.Join(jobRoot, "run.sh"),
+			Skip:          v.ShouldSkip,
+		}
+	}
+
+	err = os.Remove(path.Join(workflowLogRoot, workflowLogCacheFileName))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	return &ret, nil
+}
+
+// archiveJobLog moves results of previous execution into resumeN directory
+func archiveJobLog(jobRoot string) error {
+	var archiveDir string
+	for i := 1; ; i++ {
+		archiveDir = path.Join(jobRoot, fmt.Sprintf("resume%d", i))
+		_, err := os.Stat(archiveDir)
+		if os.IsNotExist(err) {
+			break
+		} else if err != nil {
+			return err
+		}
+	}
+
+	created := false
+	move := func(name string) error {
+		if !created {
+			err := os.MkdirAll(archiveDir, 0755)
+			if err != nil {
+				return err
+			}
+			created = true
+		}
+		return os.Rename(path.Join(jobRoot, name), path.Join(archiveDir, name))
+	}
+
+	for _, v := range resumeArchivedFiles {
+		_, err := os.Stat(path.Join(jobRoot, v))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if err = move(v); err != nil {
+			return fmt.Errorf("Cannot archive job log: %s", err.Error())
+		}
+	}
+
+	files, err := ioutil.ReadDir(jobRoot)
+	if err != nil {
+		return err
+	}
+	for _, v := range files {
+		if v.IsDir() && attemptDirRegexp.MatchString(v.Name()) {
+			if err = move(v.Name()); err != nil {
+				return fmt.Errorf("Cannot archive job log: %s", err.Error())
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestPrepareResume(t *testing.T) {
+	ClearCache()
+	tmp, err := NewTempDir("resume")
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	os.Args[0] = path.Join(tmp.originalCwd, "shellflow")
+	defer tmp.Close()
+
+	testScript := `echo 1 >> count; echo 1 > [[a]]
+test -e flag; cat ((a)) > [[b]]
+cat ((b)) > [[c]]
+`
+	env := NewEnvironment()
+	builder, err := ParseShellflow(strings.NewReader(testScript), env, make(map[string]interface{}))
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	gen, err := GenerateTaskScripts("resume.sf", "", env, builder)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	err = ExecuteLocalSingle(gen)
+	if err == nil || !IsExecutionError(err) {
+		t.Fatalf("execution error should be returned: %s", err)
+	}
+
+	err = ioutil.WriteFile("flag", []byte("ok"), 0644)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	resumed, err := PrepareResume(gen.workflowRoot, NewEnvironment())
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	if !resumed.builder.Tasks[0].ShouldSkip || resumed.builder.Tasks[1].ShouldSkip || resumed.builder.Tasks[2].ShouldSkip {
+		t.Fatalf("bad tasks to resume: %s", resumed.builder.Tasks)
+	}
+	if _, err := os.Stat(path.Join(gen.scripts[2].JobRoot, "resume1", "rc")); err != nil {
+		t.Fatalf("previous log should be archived: %s", err)
+	}
+
+	err = ExecuteLocalSingle(resumed)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	data, err := ioutil.ReadFile("count")
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	if string(data) != "1\n" {
+		t.Fatalf("finished task should not be executed again: %s", data)
+	}
+
+	log, err := CollectLogsForOneWork(gen.workflowRoot)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	for _, v := range log.JobLogs {
+		if v.State() != JobDone {
+			t.Fatalf("bad job state: %s", v)
+		}
+	}
+}
+
+func TestPrepareResumeRunning(t *testing.T) {
+	ClearCache()
+	tmp, err := NewTempDir("resume_running")
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	os.Args[0] = path.Join(tmp.originalCwd, "shellflow")
+	defer tmp.Close()
+
+	env := NewEnvironment()
+	builder, err := ParseShellflow(strings.NewReader("echo 1 > [[a]]\n"), env, make(map[string]interface{}))
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	gen, err := GenerateTaskScripts("resume.sf", "", env, builder)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	err = ioutil.WriteFile(path.Join(gen.scripts[1].JobRoot, localRunPidFile), []byte(fmt.Sprintf("%d", os.Getpid())), 0644)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	_, err = PrepareResume(gen.workflowRoot, NewEnvironment())
+	if err == nil || !strings.HasPrefix(err.Error(), "Job 1 is still running") {
+		t.Fatalf("running job should be detected: %s", err)
+	}
+}
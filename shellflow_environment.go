@@ -18,6 +18,7 @@ type Environment struct {
 	dryRun          bool
 	scriptsOnly     bool
 	rerunAll        bool
+	localJobs       int
 }
 
 // NewEnvironment creates new Envrionment value
@@ -35,5 +36,6 @@ func NewEnvironment() *Environment {
 		dryRun:          false,
 		scriptsOnly:     false,
 		rerunAll:        false,
+		localJobs:       1,
 	}
 }
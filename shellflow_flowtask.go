@@ -262,16 +262,141 @@ func (v *ForFlowTask) Subscribe(env flowscript.Environment, builder *ShellTaskBu
 	return nil
 }
 
+type IfBranch struct {
+	LineNum   int
+	Condition flowscript.Evaluable // nil for else branch
+	SubTask   []FlowTask
+}
+
+type IfFlowTask struct {
+	LineNum  int
+	Branches []*IfBranch
+}
+
+func NewIfFlowTask(condition string, lineNum int) (*IfFlowTask, error) {
+	task := &IfFlowTask{
+		LineNum:  lineNum,
+		Branches: make([]*IfBranch, 0),
+	}
+	err := task.AddBranch(condition, lineNum)
+	if err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// AddBranch starts new elif branch. An empty condition starts else branch.
+func (v *IfFlowTask) AddBranch(condition string, lineNum int) error {
+	if len(v.Branches) > 0 && v.Branches[len(v.Branches)-1].Condition == nil {
+		return fmt.Errorf("Branch after else at line %d", lineNum)
+	}
+
+	var evaluable flowscript.Evaluable
+	if condition != "" {
+		parsed, err := flowscript.ParseScript(condition)
+		if err != nil {
+			return err
+		}
+		evaluable = parsed
+	}
+
+	v.Branches = append(v.Branches, &IfBranch{
+		LineNum:   lineNum,
+		Condition: evaluable,
+		SubTask:   make([]FlowTask, 0),
+	})
+	return nil
+}
+
+func (v *IfFlowTask) AddTask(t FlowTask) {
+	branch := v.Branches[len(v.Branches)-1]
+	branch.SubTask = append(branch.SubTask, t)
+}
+
+func (v *IfFlowTask) DependentVariables() flowscript.StringSet {
+	vals := flowscript.NewStringSet()
+	for _, x := range v.Branches {
+		if x.Condition != nil {
+			vals.AddAll(flowscript.SearchDependentVariables(x.Condition))
+		}
+		for _, y := range x.SubTask {
+			vals.AddAll(y.DependentVariables())
+		}
+	}
+	return vals
+}
+
+func (v *IfFlowTask) CreatedVariables() flowscript.StringSet {
+	vals := flowscript.NewStringSet()
+	for _, x := range v.Branches {
+		if x.Condition != nil {
+			vals.AddAll(flowscript.SearchCreatedVariables(x.Condition))
+		}
+		for _, y := range x.SubTask {
+			vals.AddAll(y.CreatedVariables())
+		}
+	}
+	return vals
+}
+
+func (v *IfFlowTask) Line() int {
+	return v.LineNum
+}
+
+func (v *IfFlowTask) Subscribe(env flowscript.Environment, builder *ShellTaskBuilder) error {
+	for _, x := range v.Branches {
+		if x.Condition != nil {
+			value, err := x.Condition.Evaluate(env)
+			if err != nil {
+				return fmt.Errorf("Parse error at line %d: %s", x.LineNum, err.Error())
+			}
+			matched, err := value.AsBool()
+			if err != nil {
+				return fmt.Errorf("Bad condition at line %d: %s", x.LineNum, err.Error())
+			}
+			if !matched {
+				continue
+			}
+		}
+
+		for _, y := range x.SubTask {
+			err := y.Subscribe(env, builder)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return nil
+}
+
 type SingleShellTask struct {
 	LineNum           int
 	Script            string
+	Annotation        *SingleShellTask
 	embeddedPositions [][]int
 	evaluables        []flowscript.Evaluable
 }
 
 var embeddedFlowScriptBrace = regexp.MustCompile("{{[^}]*}}")
 
+// annotationRegexp matches start of trailing annotation like "#@ memory=8G cpu=4"
+var annotationRegexp = regexp.MustCompile(`(^|\s)#@(\s|$)`)
+
 func NewSingleShellTask(lineNum int, line string) (*SingleShellTask, error) {
+	var annotation *SingleShellTask
+	if loc := annotationRegexp.FindStringIndex(line); loc != nil {
+		var err error
+		annotation, err = NewSingleShellTask(lineNum, line[loc[1]:])
+		if err != nil {
+			return nil, err
+		}
+		if annotation.Annotation != nil {
+			return nil, fmt.Errorf("Multiple annotations at line %d", lineNum)
+		}
+		line = strings.TrimSpace(line[:loc[0]])
+	}
+
 	positions := embeddedFlowScriptBrace.FindAllStringIndex(line, 100)
 	var evaluables []flowscript.Evaluable
 	for _, v := range positions {
@@ -286,6 +411,7 @@ func NewSingleShellTask(lineNum int, line string) (*SingleShellTask, error) {
 	return &SingleShellTask{
 		LineNum:           lineNum,
 		Script:            line,
+		Annotation:        annotation,
 		embeddedPositions: positions,
 		evaluables:        evaluables,
 	}, nil
@@ -296,6 +422,9 @@ func (t *SingleShellTask) DependentVariables() flowscript.StringSet {
 	for _, v := range t.evaluables {
 		vars.AddAll(flowscript.SearchDependentVariables(v))
 	}
+	if t.Annotation != nil {
+		vars.AddAll(t.Annotation.DependentVariables())
+	}
 	return vars
 }
 
@@ -304,6 +433,9 @@ func (t *SingleShellTask) CreatedVariables() flowscript.StringSet {
 	for _, v := range t.evaluables {
 		vars.AddAll(flowscript.SearchCreatedVariables(v))
 	}
+	if t.Annotation != nil {
+		vars.AddAll(t.Annotation.CreatedVariables())
+	}
 	return vars
 }
 
@@ -328,13 +460,40 @@ func (t *SingleShellTask) EvaluatedShell(env flowscript.Environment) (string, er
 	return line, nil
 }
 
+// EvaluatedAnnotation evaluates the annotation and returns it as key-value
+// pairs. A key without value like "immediate" has an empty value.
+func (t *SingleShellTask) EvaluatedAnnotation(env flowscript.Environment) (map[string]string, error) {
+	annotation := make(map[string]string)
+	if t.Annotation == nil {
+		return annotation, nil
+	}
+
+	line, err := t.Annotation.EvaluatedShell(env)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range strings.Fields(line) {
+		keyValue := strings.SplitN(v, "=", 2)
+		if len(keyValue) == 2 {
+			annotation[keyValue[0]] = keyValue[1]
+		} else {
+			annotation[keyValue[0]] = ""
+		}
+	}
+	return annotation, nil
+}
+
 func (t *SingleShellTask) Subscribe(env flowscript.Environment, builder *ShellTaskBuilder) error {
 	line, e := t.EvaluatedShell(env)
 	if e != nil {
 		return fmt.Errorf("Parse error at line %d: %s", t.LineNum, e.Error())
 	}
+	annotation, e := t.EvaluatedAnnotation(env)
+	if e != nil {
+		return fmt.Errorf("Parse error at line %d: %s", t.LineNum, e.Error())
+	}
 
-	_, e = builder.CreateShellTask(t.LineNum, line)
+	_, e = builder.CreateShellTaskWithAnnotation(t.LineNum, line, annotation, env)
 	if e != nil {
 		return fmt.Errorf(" error at line %d: %s", t.LineNum, e.Error())
 	}
@@ -347,6 +506,8 @@ func (t *SingleShellTask) Line() int {
 }
 
 var forBlockRegexp = regexp.MustCompile(`^for\s+(\w+)\s+in\s+(\S.+?)\s*(;?\s*do\s*)?$`)
+var ifBlockStartRegexp = regexp.MustCompile(`^(if|elif)\s+{{`)
+var ifBlockRegexp = regexp.MustCompile(`^(if|elif)\s+{{(.+)}}\s*(;?\s*then\s*)?$`)
 
 func ParseShellflowBlock(reader io.Reader, env *Environment) (FlowTaskBlock, string, error) {
 	workflowContent := bytes.NewBuffer(nil)
@@ -379,11 +540,54 @@ func ParseShellflowBlock(reader io.Reader, env *Environment) (FlowTaskBlock, str
 			continue
 		} else if strings.HasPrefix(line, "done") {
 			if line == "done" {
+				if _, ok := blockStack[len(blockStack)-1].(*ForFlowTask); !ok {
+					return nil, "", fmt.Errorf("done without for at line %d", lineNum)
+				}
 				blockStack = blockStack[0 : len(blockStack)-1]
 			} else {
 				return nil, "", fmt.Errorf("Invalid done statment: %s", line)
 			}
 			continue
+		} else if ifBlockStartRegexp.MatchString(line) {
+			submatch := ifBlockRegexp.FindStringSubmatch(line)
+			if submatch == nil {
+				return nil, "", fmt.Errorf("Invalid %s statement: %s", strings.Fields(line)[0], line)
+			}
+
+			if submatch[1] == "if" {
+				ifTask, err := NewIfFlowTask(submatch[2], lineNum)
+				if err != nil {
+					return nil, "", err
+				}
+				blockStack[len(blockStack)-1].AddTask(ifTask)
+				blockStack = append(blockStack, ifTask)
+			} else {
+				ifTask, ok := blockStack[len(blockStack)-1].(*IfFlowTask)
+				if !ok {
+					return nil, "", fmt.Errorf("elif without if at line %d", lineNum)
+				}
+				err := ifTask.AddBranch(submatch[2], lineNum)
+				if err != nil {
+					return nil, "", err
+				}
+			}
+			continue
+		} else if line == "else" {
+			ifTask, ok := blockStack[len(blockStack)-1].(*IfFlowTask)
+			if !ok {
+				return nil, "", fmt.Errorf("else without if at line %d", lineNum)
+			}
+			err := ifTask.AddBranch("", lineNum)
+			if err != nil {
+				return nil, "", err
+			}
+			continue
+		} else if line == "fi" {
+			if _, ok := blockStack[len(blockStack)-1].(*IfFlowTask); !ok {
+				return nil, "", fmt.Errorf("fi without if at line %d", lineNum)
+			}
+			blockStack = blockStack[0 : len(blockStack)-1]
+			continue
 		} else if strings.HasPrefix(line, "#%") {
 			task, err = NewSingleFlowScriptTask(lineNum, line)
 		} else if strings.HasPrefix(line, "#") || len(line) == 0 {
@@ -403,6 +607,10 @@ func ParseShellflowBlock(reader io.Reader, env *Environment) (FlowTaskBlock, str
 		return nil, "", e
 	}
 
+	if len(blockStack) > 1 {
+		return nil, "", fmt.Errorf("Block started at line %d is not closed", blockStack[len(blockStack)-1].Line())
+	}
+
 	return blockStack[0], string(workflowContent.Bytes()), nil
 }
 
@@ -417,6 +625,8 @@ func ParseShellflow(reader io.Reader, env *Environment, param map[string]interfa
 			//fmt.Printf("key = %s   numeric value = %f\n", key, value)
 			floatValue := value.(float64)
 			env.flowEnvironment.Assign(key, flowscript.NewIntValue(int64(floatValue)))
+		case bool:
+			env.flowEnvironment.Assign(key, flowscript.NewBoolValue(value.(bool)))
 		default:
 			return nil, fmt.Errorf("Unknown parameter type %s = %s", key, value)
 		}
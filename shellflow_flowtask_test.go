@@ -3,6 +3,7 @@ package main
 import (
 	"encoding/json"
 	"reflect"
+	"strconv"
 	"strings"
 	"testing"
 
@@ -800,3 +801,109 @@ func TestForItemSplit(t *testing.T) {
 		t.Fatalf("bad split result %s", x)
 	}
 }
+
+func TestParseShellflowIf(t *testing.T) {
+	testScript := `#!/usr/bin/shellflow
+#% x = 1
+if {{use_bqsr}}; then
+    bqsr {{x}}
+    if {{x}}
+        nested {{x}}
+    fi
+elif {{y}}; then
+    no_bqsr {{y}}
+else
+    otherwise
+fi
+if [ -f foo ]; then echo foo; fi
+`
+	testCases := []struct {
+		param    map[string]interface{}
+		expected []string
+	}{
+		{map[string]interface{}{"use_bqsr": true, "y": "foo"}, []string{"bqsr 1", "nested 1"}},
+		{map[string]interface{}{"use_bqsr": false, "y": "foo"}, []string{"no_bqsr foo"}},
+		{map[string]interface{}{"use_bqsr": 0.0, "y": ""}, []string{"otherwise"}},
+	}
+
+	for _, v := range testCases {
+		env := NewEnvironment()
+		builder, err := ParseShellflow(strings.NewReader(testScript), env, v.param)
+		if err != nil {
+			t.Fatalf("Error: %s", err.Error())
+		}
+
+		scripts := make([]string, 0)
+		for _, x := range builder.Tasks {
+			scripts = append(scripts, x.ShellScript)
+		}
+		expected := append(v.expected, "if [ -f foo ]; then echo foo; fi")
+		if !reflect.DeepEqual(scripts, expected) {
+			t.Fatalf("Bad tasks: %s / expected: %s", scripts, expected)
+		}
+	}
+
+	badScripts := map[string]string{
+		"if {{x}}\necho\n":             "Block started at line 1 is not closed",
+		"echo\nfi\n":                   "fi without if at line 2",
+		"else\n":                       "else without if at line 1",
+		"elif {{x}}\n":                 "elif without if at line 1",
+		"if {{x}}\nelse\nelif {{y}}\n": "Branch after else at line 3",
+		"if {{x}}\ndone\n":             "done without for at line 2",
+		"if {{x}} foo\n":               "Invalid if statement: if {{x}} foo",
+	}
+
+	for k, v := range badScripts {
+		_, _, err := ParseShellflowBlock(strings.NewReader(k), NewEnvironment())
+		if err == nil || err.Error() != v {
+			t.Fatalf("Bad error for %s: %s", strconv.Quote(k), err)
+		}
+	}
+}
+
+func TestParseShellflowAnnotation(t *testing.T) {
+	testScript := `#% mem = "8G"
+gatk HaplotypeCaller ((input.bam)) [[output.vcf]] #@ memory={{mem}} cpu=4 retry=2 immediate
+gatk CountReads ((input.bam)) > [[count.txt]]
+echo "#@" > [[hash.txt]] #@ walltime=1h
+`
+	env := NewEnvironment()
+	builder, err := ParseShellflow(strings.NewReader(testScript), env, make(map[string]interface{}))
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+
+	if v := builder.Tasks[0].ShellScript; v != "gatk HaplotypeCaller input.bam output.vcf" {
+		t.Fatalf("Bad script: %s", v)
+	}
+	conf := builder.Tasks[0].CommandConfiguration
+	if conf.Memory != "8G" || conf.CPU != 4 || conf.Retry != 2 || !conf.RunImmediate {
+		t.Fatalf("Bad configuration: %s", conf.String())
+	}
+
+	conf = builder.Tasks[1].CommandConfiguration
+	if conf.Memory != "" || conf.CPU != 0 || conf.Retry != 0 || conf.RunImmediate {
+		t.Fatalf("Bad configuration: %s", conf.String())
+	}
+
+	if v := builder.Tasks[2].ShellScript; v != "echo \"#@\" > hash.txt" {
+		t.Fatalf("Bad script: %s", v)
+	}
+	if v := builder.Tasks[2].CommandConfiguration.Walltime; v != "1h" {
+		t.Fatalf("Bad walltime: %s", v)
+	}
+
+	badScripts := map[string]string{
+		"echo #@ foo=1\n":          "Bad annotation at line 1: Unknown annotation: foo",
+		"echo #@ cpu=x\n":          "Bad annotation at line 1: Invalid cpu: x",
+		"echo #@ memory=1X\n":      "Bad configuration at line 1: Invalid memory: 1X",
+		"echo #@ cpu=1 #@ gpu=1\n": "Multiple annotations at line 1",
+	}
+
+	for k, v := range badScripts {
+		_, err := ParseShellflow(strings.NewReader(k), NewEnvironment(), make(map[string]interface{}))
+		if err == nil || !strings.HasSuffix(err.Error(), v) {
+			t.Fatalf("Bad error for %s: %s", strconv.Quote(k), err)
+		}
+	}
+}
@@ -19,6 +19,8 @@ type ShellTaskBuilder struct {
 	WorkflowContent     string
 	workflowLogs        WorkflowLogArray
 	config              *Configuration
+	fingerprints        map[int]*TaskFingerprint
+	rerunReasons        map[int][]string
 }
 
 func NewShellTaskBuilder() (*ShellTaskBuilder, error) {
@@ -32,10 +34,30 @@ func NewShellTaskBuilder() (*ShellTaskBuilder, error) {
 		Tasks:               make([]*ShellTask, 0),
 		MissingCreatorFiles: flowscript.NewStringSet(),
 		workflowLogs:        logs,
+		fingerprints:        make(map[int]*TaskFingerprint),
+		rerunReasons:        make(map[int][]string),
 	}, nil
 }
 
+// Fingerprint returns a fingerprint of a task. nil is returned if a task is
+// loaded from a workflow log.
+func (b *ShellTaskBuilder) Fingerprint(taskID int) *TaskFingerprint {
+	return b.fingerprints[taskID]
+}
+
+// RerunReasons returns reasons why a task is not reused
+func (b *ShellTaskBuilder) RerunReasons(taskID int) []string {
+	return b.rerunReasons[taskID]
+}
+
 func (b *ShellTaskBuilder) CreateShellTask(lineNum int, line string) (*ShellTask, error) {
+	return b.CreateShellTaskWithAnnotation(lineNum, line, nil, nil)
+}
+
+// CreateShellTaskWithAnnotation creates a task with an annotation written
+// after "#@". The annotation overrides options from configuration file.
+// Flowscript in environment variables is evaluated with flowEnv.
+func (b *ShellTaskBuilder) CreateShellTaskWithAnnotation(lineNum int, line string, annotation map[string]string, flowEnv flowscript.Environment) (*ShellTask, error) {
 	var formattedLine strings.Builder
 	dependentFiles := flowscript.NewStringSet()
 	creatingFiles := flowscript.NewStringSet()
@@ -127,20 +149,6 @@ func (b *ShellTaskBuilder) CreateShellTask(lineNum int, line string) (*ShellTask
 	//fmt.Printf("skippable: %v : %s\n", skippable, formattedLine.String())
 	shellScript := formattedLine.String()
 
-	shouldSkip := false
-	var reuseLogPath *JobLog
-	if skippable {
-		cwd, err := os.Getwd()
-		if err != nil {
-			return nil, err
-		}
-		job := b.workflowLogs.SearchReusableJob(shellScript, cwd, dependentFiles, creatingFiles)
-		if job != nil { // found
-			shouldSkip = true
-			reuseLogPath = job
-		}
-	}
-
 	// check config
 	commandConf := CommandConfiguration{
 		RegExp:    "",
@@ -160,6 +168,49 @@ func (b *ShellTaskBuilder) CreateShellTask(lineNum int, line string) (*ShellTask
 		}
 	}
 
+	if err := commandConf.ApplyAnnotation(annotation); err != nil {
+		return nil, fmt.Errorf("Bad annotation at line %d: %s", lineNum, err.Error())
+	}
+
+	if commandConf.Timeout == "" {
+		commandConf.Timeout = conf.Timeout
+	}
+	if err := commandConf.Validate(); err != nil {
+		return nil, fmt.Errorf("Bad configuration at line %d: %s", lineNum, err.Error())
+	}
+
+	environment, err := taskEnvironment(conf.Environment, commandConf.Environment, flowEnv)
+	if err != nil {
+		return nil, fmt.Errorf("Bad environment at line %d: %s", lineNum, err.Error())
+	}
+
+	fingerprint, err := NewTaskFingerprint(shellScript, &commandConf, environment)
+	if err != nil {
+		return nil, err
+	}
+
+	shouldSkip := false
+	var reuseLogPath *JobLog
+	var rerunReasons []string
+	if skippable {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+		job, reasons := b.workflowLogs.SearchReusableJob(shellScript, cwd, dependentFiles, creatingFiles, &commandConf, fingerprint)
+		if job != nil { // found
+			shouldSkip = true
+			reuseLogPath = job
+		}
+		rerunReasons = reasons
+	} else {
+		for _, v := range dependentTaskID {
+			if !b.Tasks[v-1].ShouldSkip {
+				rerunReasons = append(rerunReasons, fmt.Sprintf("upstream task %d is run", v))
+			}
+		}
+	}
+
 	b.CurrentID++
 	task := ShellTask{
 		LineNum:              lineNum,
@@ -171,6 +222,11 @@ func (b *ShellTaskBuilder) CreateShellTask(lineNum int, line string) (*ShellTask
 		ShouldSkip:           shouldSkip,
 		ReuseLog:             reuseLogPath,
 		CommandConfiguration: commandConf,
+		Environment:          environment,
+	}
+	b.fingerprints[task.ID] = fingerprint
+	if rerunReasons != nil {
+		b.rerunReasons[task.ID] = rerunReasons
 	}
 
 	b.Tasks = append(b.Tasks, &task)
@@ -238,6 +294,39 @@ type ShellTask struct {
 	ShouldSkip           bool
 	ReuseLog             *JobLog
 	CommandConfiguration CommandConfiguration
+	Environment          map[string]string
+}
+
+var environmentNameRegexp = regexp.MustCompile("^[A-Za-z_][A-Za-z0-9_]*$")
+
+// taskEnvironment merges global and per-command environment variables, and
+// evaluates flowscript in values. nil is returned if no variable is configured.
+func taskEnvironment(global map[string]string, command map[string]string, flowEnv flowscript.Environment) (map[string]string, error) {
+	if len(global) == 0 && len(command) == 0 {
+		return nil, nil
+	}
+	if flowEnv == nil {
+		flowEnv = flowscript.NewGlobalEnvironment()
+	}
+
+	environment := make(map[string]string)
+	for _, m := range []map[string]string{global, command} {
+		for k, v := range m {
+			if !environmentNameRegexp.MatchString(k) {
+				return nil, fmt.Errorf("Invalid environment variable name: %s", k)
+			}
+			task, err := NewSingleShellTask(0, v)
+			if err != nil {
+				return nil, fmt.Errorf("Cannot parse %s: %s", k, err.Error())
+			}
+			value, err := task.EvaluatedShell(flowEnv)
+			if err != nil {
+				return nil, fmt.Errorf("Cannot evaluate %s: %s", k, err.Error())
+			}
+			environment[k] = value
+		}
+	}
+	return environment, nil
 }
 
 func (v *ShellTask) String() string {
@@ -26,8 +26,8 @@ func TestShellTask(t *testing.T) {
 			DependentFiles:       flowscript.NewStringSet(),
 			CreatingFiles:        flowscript.NewStringSet(),
 			DependentTaskID:      []int{},
-			CommandConfiguration: CommandConfiguration{RegExp: "java .*", SGEOption: []string{"-l", "s_vmem=40G,mem_req=40G"}},
-		}) {
+			CommandConfiguration: CommandConfiguration{RegExp: "java .*", SGEOption: []string{"-l", "s_vmem=40G,mem_req=40G"}, Source: shellTask.CommandConfiguration.Source},
+		}) || shellTask.CommandConfiguration.Source == "" {
 			t.Fatalf("Invalid shell task: %s", shellTask)
 		}
 	}
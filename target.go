@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SelectTargetTasks removes tasks which are not required to create target
+// files or to run tasks at target lines. Tasks which a required task depends
+// on are also required.
+func SelectTargetTasks(builder *ShellTaskBuilder, targetFiles []string, targetLines []int) error {
+	if len(targetFiles) == 0 && len(targetLines) == 0 {
+		return nil
+	}
+
+	taskByID := make(map[int]*ShellTask)
+	for _, v := range builder.Tasks {
+		taskByID[v.ID] = v
+	}
+
+	required := make(map[int]bool)
+	var require func(task *ShellTask)
+	require = func(task *ShellTask) {
+		if required[task.ID] {
+			return
+		}
+		required[task.ID] = true
+		for _, d := range task.DependentTaskID {
+			if dependent, ok := taskByID[d]; ok {
+				require(dependent)
+			}
+		}
+	}
+
+	for _, file := range targetFiles {
+		found := false
+		for _, v := range builder.Tasks {
+			for _, x := range v.CreatingFiles.Array() {
+				if filepath.Clean(x) == filepath.Clean(file) {
+					require(v)
+					found = true
+				}
+			}
+		}
+		if !found {
+			return fmt.Errorf("No task creates %s", file)
+		}
+	}
+
+	for _, line := range targetLines {
+		tasks := tasksAtLine(builder, line)
+		if len(tasks) == 0 {
+			return fmt.Errorf("No task at line %d", line)
+		}
+		for _, v := range tasks {
+			require(v)
+		}
+	}
+
+	selected := make([]*ShellTask, 0)
+	for _, v := range builder.Tasks {
+		if required[v.ID] {
+			selected = append(selected, v)
+		}
+	}
+	builder.Tasks = selected
+	return nil
+}
+
+// ForceRerunFrom marks tasks at lines and all tasks depending on them to
+// run again even if their results can be reused.
+func ForceRerunFrom(builder *ShellTaskBuilder, lines []int) error {
+	forced := make(map[int]bool)
+	for _, line := range lines {
+		tasks := tasksAtLine(builder, line)
+		if len(tasks) == 0 {
+			return fmt.Errorf("No task at line %d", line)
+		}
+		for _, v := range tasks {
+			forced[v.ID] = true
+		}
+	}
+	forceRerunDownstream(builder, forced)
+	return nil
+}
+
+// ForceRerunTasks marks tasks matched to specs and all tasks depending on
+// them to run again. A spec is one of "id:N" (task ID), "line:N" (line
+// number), "regexp:PATTERN" (regular expression of a command) and
+// "file:GLOB" (glob of an output file). A spec without prefix is treated as
+// a glob of an output file.
+func ForceRerunTasks(builder *ShellTaskBuilder, specs []string) error {
+	forced := make(map[int]bool)
+	for _, spec := range specs {
+		match, err := taskSpecMatcher(spec)
+		if err != nil {
+			return err
+		}
+		found := false
+		for _, v := range builder.Tasks {
+			if match(v) {
+				forced[v.ID] = true
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("No task matches %s", spec)
+		}
+	}
+	forceRerunDownstream(builder, forced)
+	return nil
+}
+
+func taskSpecMatcher(spec string) (func(task *ShellTask) bool, error) {
+	kind, value := "file", spec
+	if i := strings.Index(spec, ":"); i >= 0 {
+		switch spec[:i] {
+		case "id", "line", "regexp", "file":
+			kind, value = spec[:i], spec[i+1:]
+		}
+	}
+
+	switch kind {
+	case "id", "line":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid task specification: %s", spec)
+		}
+		if kind == "id" {
+			return func(task *ShellTask) bool { return task.ID == n }, nil
+		}
+		return func(task *ShellTask) bool { return task.LineNum == n }, nil
+	case "regexp":
+		r, err := regexp.Compile(value)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid task specification: %s: %s", spec, err.Error())
+		}
+		return func(task *ShellTask) bool { return r.MatchString(task.ShellScript) }, nil
+	}
+
+	if _, err := filepath.Match(value, ""); err != nil {
+		return nil, fmt.Errorf("Invalid task specification: %s: %s", spec, err.Error())
+	}
+	return func(task *ShellTask) bool {
+		for _, x := range task.CreatingFiles.Array() {
+			if matched, _ := filepath.Match(filepath.Clean(value), filepath.Clean(x)); matched {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// forceRerunDownstream clears ShouldSkip of forced tasks and all tasks
+// depending on them.
+func forceRerunDownstream(builder *ShellTaskBuilder, forced map[int]bool) {
+	// a task depends only on tasks with smaller ID
+	for _, v := range builder.Tasks {
+		reasons := []string{"rerun is requested"}
+		if !forced[v.ID] {
+			reasons = nil
+			for _, d := range v.DependentTaskID {
+				if forced[d] {
+					reasons = append(reasons, fmt.Sprintf("upstream task %d is run", d))
+				}
+			}
+			forced[v.ID] = len(reasons) > 0
+		}
+		if forced[v.ID] && v.ShouldSkip {
+			v.ShouldSkip = false
+			if builder.rerunReasons != nil {
+				builder.rerunReasons[v.ID] = reasons
+			}
+		}
+	}
+}
+
+func tasksAtLine(builder *ShellTaskBuilder, line int) []*ShellTask {
+	tasks := make([]*ShellTask, 0)
+	for _, v := range builder.Tasks {
+		if v.LineNum == line {
+			tasks = append(tasks, v)
+		}
+	}
+	return tasks
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const targetTestScript = `echo 1 > [[a]]
+cat ((a)) > [[b]]
+cat ((a)) > [[c]]
+cat ((b)) > [[d]]
+cat ((c)) ((d)) > [[e]]
+`
+
+func taskLines(builder *ShellTaskBuilder) []int {
+	lines := make([]int, 0)
+	for _, v := range builder.Tasks {
+		lines = append(lines, v.LineNum)
+	}
+	return lines
+}
+
+func TestSelectTargetTasks(t *testing.T) {
+	ClearCache()
+	tmp, err := NewTempDir("target")
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	defer tmp.Close()
+
+	testCases := []struct {
+		files    []string
+		lines    []int
+		expected []int
+	}{
+		{nil, nil, []int{1, 2, 3, 4, 5}},
+		{[]string{"d"}, nil, []int{1, 2, 4}},
+		{[]string{"./c"}, nil, []int{1, 3}},
+		{nil, []int{3}, []int{1, 3}},
+		{[]string{"b"}, []int{3}, []int{1, 2, 3}},
+		{[]string{"e"}, nil, []int{1, 2, 3, 4, 5}},
+	}
+
+	for _, v := range testCases {
+		builder, err := ParseShellflow(strings.NewReader(targetTestScript), NewEnvironment(), make(map[string]interface{}))
+		if err != nil {
+			t.Fatalf("error: %s", err.Error())
+		}
+		err = SelectTargetTasks(builder, v.files, v.lines)
+		if err != nil {
+			t.Fatalf("error: %s", err.Error())
+		}
+		if lines := taskLines(builder); !reflect.DeepEqual(lines, v.expected) {
+			t.Fatalf("bad tasks for %s %d: %d", v.files, v.lines, lines)
+		}
+	}
+
+	builder, err := ParseShellflow(strings.NewReader(targetTestScript), NewEnvironment(), make(map[string]interface{}))
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	if err := SelectTargetTasks(builder, []string{"x"}, nil); err == nil || err.Error() != "No task creates x" {
+		t.Fatalf("bad error: %s", err)
+	}
+	if err := SelectTargetTasks(builder, nil, []int{10}); err == nil || err.Error() != "No task at line 10" {
+		t.Fatalf("bad error: %s", err)
+	}
+}
+
+func TestForceRerunFrom(t *testing.T) {
+	ClearCache()
+	tmp, err := NewTempDir("rerun_from")
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	defer tmp.Close()
+
+	builder, err := ParseShellflow(strings.NewReader(targetTestScript), NewEnvironment(), make(map[string]interface{}))
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	for _, v := range builder.Tasks {
+		v.ShouldSkip = true
+	}
+
+	err = ForceRerunFrom(builder, []int{2})
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	skipped := make([]bool, 0)
+	for _, v := range builder.Tasks {
+		skipped = append(skipped, v.ShouldSkip)
+	}
+	if expected := []bool{true, false, true, false, false}; !reflect.DeepEqual(skipped, expected) {
+		t.Fatalf("bad skip flags: %v", skipped)
+	}
+
+	if err := ForceRerunFrom(builder, []int{10}); err == nil {
+		t.Fatalf("unknown line should be error")
+	}
+}
+
+func TestForceRerunTasks(t *testing.T) {
+	ClearCache()
+	tmp, err := NewTempDir("rerun_tasks")
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	defer tmp.Close()
+
+	testCases := []struct {
+		specs    []string
+		expected []bool
+	}{
+		{nil, []bool{true, true, true, true, true}},
+		{[]string{"id:3"}, []bool{true, true, false, true, false}},
+		{[]string{"line:4"}, []bool{true, true, true, false, false}},
+		{[]string{"regexp:^cat \\(?\\(?c"}, []bool{true, true, true, true, false}},
+		{[]string{"file:d"}, []bool{true, true, true, false, false}},
+		{[]string{"[bc]"}, []bool{true, false, false, false, false}},
+		{[]string{"id:5", "file:./b"}, []bool{true, false, true, false, false}},
+	}
+
+	for _, v := range testCases {
+		builder, err := ParseShellflow(strings.NewReader(targetTestScript), NewEnvironment(), make(map[string]interface{}))
+		if err != nil {
+			t.Fatalf("error: %s", err.Error())
+		}
+		for _, x := range builder.Tasks {
+			x.ShouldSkip = true
+		}
+
+		err = ForceRerunTasks(builder, v.specs)
+		if err != nil {
+			t.Fatalf("error: %s", err.Error())
+		}
+		skipped := make([]bool, 0)
+		for _, x := range builder.Tasks {
+			skipped = append(skipped, x.ShouldSkip)
+		}
+		if !reflect.DeepEqual(skipped, v.expected) {
+			t.Fatalf("bad skip flags for %s: %v", v.specs, skipped)
+		}
+	}
+
+	builder, err := ParseShellflow(strings.NewReader(targetTestScript), NewEnvironment(), make(map[string]interface{}))
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	for k, v := range map[string]string{
+		"id:x":     "Invalid task specification: id:x",
+		"id:10":    "No task matches id:10",
+		"regexp:(": "Invalid task specification: regexp:(: error parsing regexp: missing closing ): `(`",
+		"file:z":   "No task matches file:z",
+	} {
+		if err := ForceRerunTasks(builder, []string{k}); err == nil || err.Error() != v {
+			t.Fatalf("bad error for %s: %s", k, err)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	condaEnvironmentFileName = "conda-environment.txt"
+	modulesFileName          = "modules.txt"
+)
+
+// writeTaskEnvironmentScript writes a part of run.sh which activates a conda
+// environment and loads environment modules. Resolved environment is
+// recorded in a job log directory.
+func writeTaskEnvironmentScript(w io.Writer, conf *CommandConfiguration, jobDir string) {
+	if len(conf.Modules) > 0 {
+		modules := make([]string, len(conf.Modules))
+		for i, v := range conf.Modules {
+			modules[i] = strconv.Quote(v)
+		}
+		fmt.Fprintf(w, `if ! type module > /dev/null 2>&1; then
+    . /etc/profile.d/modules.sh || exit 1
+fi
+module load %s || exit 1
+module list > "%s/%s" 2>&1
+`, strings.Join(modules, " "), jobDir, modulesFileName)
+	}
+
+	if conf.Conda != "" {
+		fmt.Fprintf(w, `eval "$(conda shell.bash hook)" || exit 1
+conda activate %s || exit 1
+conda list --explicit > "%s/%s" || exit 1
+`, strconv.Quote(conf.Conda), jobDir, condaEnvironmentFileName)
+	}
+}
+
+var environmentValueEscape = strings.NewReplacer("\\", "\\\\", "\"", "\\\"")
+
+// writeEnvironmentVariables writes export statements of environment
+// variables. Values are enclosed with double quotes, so shell variables
+// like $HOME are expanded.
+func writeEnvironmentVariables(w io.Writer, environment map[string]string) {
+	keys := make([]string, 0, len(environment))
+	for k := range environment {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "export %s=\"%s\"\n", k, environmentValueEscape.Replace(environment[k]))
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+const fakeConda = `#!/bin/bash
+FAKE_DIR="$(dirname "$0")"
+echo "$@" >> "$FAKE_DIR/conda.log"
+if [ "$1" = "list" ]; then
+    echo "@EXPLICIT"
+    echo "https://conda.anaconda.org/bioconda/linux-64/samtools-1.9-h8571acd_11.tar.bz2"
+fi
+`
+
+const fakeModule = `#!/bin/bash
+FAKE_DIR="$(dirname "$0")"
+echo "$@" >> "$FAKE_DIR/module.log"
+if [ "$1" = "list" ]; then
+    echo "Currently Loaded Modulefiles:" 1>&2
+    echo " 1) gatk/4.0.10   2) samtools/1.9" 1>&2
+fi
+`
+
+func TestExecuteWithTaskEnvironment(t *testing.T) {
+	ClearCache()
+	fakeDir, cleanup := setupFakeCommands(t, map[string]string{"conda": fakeConda, "module": fakeModule})
+	defer cleanup()
+
+	tmp, err := NewTempDir("task_environment")
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	os.Args[0] = path.Join(tmp.originalCwd, "shellflow")
+	defer tmp.Close()
+
+	testScript := `echo hello > [[a]] #@ conda=samtools modules=gatk/4.0.10,samtools/1.9
+`
+	env := NewEnvironment()
+	builder, err := ParseShellflow(strings.NewReader(testScript), env, make(map[string]interface{}))
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	gen, err := GenerateTaskScripts("environment.sf", "", env, builder)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	err = ExecuteLocalSingle(gen)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	for k, v := range map[string]string{
+		path.Join(fakeDir, "conda.log"):                             "shell.bash hook\nactivate samtools\nlist --explicit\n",
+		path.Join(fakeDir, "module.log"):                            "load gatk/4.0.10 samtools/1.9\nlist\n",
+		path.Join(gen.scripts[1].JobRoot, condaEnvironmentFileName): "@EXPLICIT\nhttps://conda.anaconda.org/bioconda/linux-64/samtools-1.9-h8571acd_11.tar.bz2\n",
+		path.Join(gen.scripts[1].JobRoot, modulesFileName):          "Currently Loaded Modulefiles:\n 1) gatk/4.0.10   2) samtools/1.9\n",
+	} {
+		data, err := ioutil.ReadFile(k)
+		if err != nil {
+			t.Fatalf("error: %s", err.Error())
+		}
+		if string(data) != v {
+			t.Fatalf("bad %s: %s", k, data)
+		}
+	}
+
+	// resolved environment is copied to a log of reused job
+	ClearCache()
+	builder, err = ParseShellflow(strings.NewReader(testScript), env, make(map[string]interface{}))
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	reused, err := GenerateTaskScripts("environment.sf", "", env, builder)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	if !reused.builder.Tasks[0].ShouldSkip {
+		t.Fatalf("job should be reused")
+	}
+	for _, v := range []string{condaEnvironmentFileName, modulesFileName} {
+		original, err := ioutil.ReadFile(path.Join(gen.scripts[1].JobRoot, v))
+		if err != nil {
+			t.Fatalf("error: %s", err.Error())
+		}
+		copied, err := ioutil.ReadFile(path.Join(reused.scripts[1].JobRoot, v))
+		if err != nil {
+			t.Fatalf("error: %s", err.Error())
+		}
+		if string(copied) != string(original) {
+			t.Fatalf("bad %s: %s", v, copied)
+		}
+	}
+}
+
+func TestExecuteWithEnvironmentVariables(t *testing.T) {
+	ClearCache()
+	tmp, err := NewTempDir("environment_variables")
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	os.Args[0] = path.Join(tmp.originalCwd, "shellflow")
+	defer tmp.Close()
+
+	err = ioutil.WriteFile("shellflow.toml", []byte(`[Environment]
+TMPDIR = "/tmp/{{sample}}"
+JAVA_OPTS = "-Xmx1g"
+
+[[Command]]
+RegExp = "java"
+[Command.Environment]
+JAVA_OPTS = "-Xmx4g \"-Dname={{sample}}\""
+`), 0644)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	testScript := `#% sample = "foo"
+echo "$TMPDIR $JAVA_OPTS" > [[a]]
+echo java "$JAVA_OPTS" > [[b]]
+`
+	env := NewEnvironment()
+	builder, err := ParseShellflow(strings.NewReader(testScript), env, make(map[string]interface{}))
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	gen, err := GenerateTaskScripts("environment.sf", "", env, builder)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	err = ExecuteLocalSingle(gen)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+
+	for k, v := range map[string]string{
+		"a": "/tmp/foo -Xmx1g\n",
+		"b": "java -Xmx4g \"-Dname=foo\"\n",
+	} {
+		data, err := ioutil.ReadFile(k)
+		if err != nil {
+			t.Fatalf("error: %s", err.Error())
+		}
+		if string(data) != v {
+			t.Fatalf("bad %s: %s", k, data)
+		}
+	}
+
+	// effective environment is recorded
+	var metadata WorkflowMetaData
+	err = LoadJsonFromFile(path.Join(gen.workflowRoot, "runtime.json"), &metadata)
+	if err != nil {
+		t.Fatalf("error: %s", err.Error())
+	}
+	if v := metadata.Tasks[1].Environment["JAVA_OPTS"]; v != "-Xmx4g \"-Dname=foo\"" {
+		t.Fatalf("bad environment: %s", v)
+	}
+
+	_, err = taskEnvironment(map[string]string{"BAD-NAME": "1"}, nil, nil)
+	if err == nil {
+		t.Fatalf("invalid name should be error")
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"os"
 	"path"
 	"reflect"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
@@ -27,6 +28,7 @@ const (
 	WorkflowRunning
 	WorkflowFailed
 	WorkflowUnknown
+	WorkflowCancelled
 )
 
 //go:generate stringer -type=JobState
@@ -38,6 +40,8 @@ const (
 	JobFailed
 	JobPending
 	JobUnknown
+	JobTimeout
+	JobCancelled
 )
 
 type WorkflowLog struct {
@@ -46,6 +50,7 @@ type WorkflowLog struct {
 	ParameterFile   string
 	StartDate       time.Time
 	ChangedInput    []string
+	TouchedInput    []string
 	JobLogs         []*JobLog
 }
 
@@ -68,6 +73,12 @@ func (v *WorkflowLog) IsDone() bool {
 }
 
 func (v *WorkflowLog) State() WorkflowState {
+	for _, x := range v.JobLogs {
+		if x.State() == JobCancelled {
+			return WorkflowCancelled
+		}
+	}
+
 	for _, x := range v.JobLogs {
 		if x.ExitCode > 0 {
 			return WorkflowFailed
@@ -109,9 +120,12 @@ func (v *WorkflowLog) Summary(failedOnly bool) string {
 		fmt.Fprintf(buf, " %s", x)
 	}
 	fmt.Fprint(buf, "\n")
+	if len(v.TouchedInput) > 0 {
+		fmt.Fprintf(buf, " Touched Input Files: %s\n", strings.Join(v.TouchedInput, " "))
+	}
 
 	for _, j := range v.JobLogs {
-		if j.State() != JobFailed && failedOnly {
+		if j.State() != JobFailed && j.State() != JobTimeout && failedOnly {
 			continue
 		}
 
@@ -120,6 +134,12 @@ func (v *WorkflowLog) Summary(failedOnly bool) string {
 		if j.ExitCode >= 0 {
 			fmt.Fprintf(buf, "         Exit code: %d\n", j.ExitCode)
 		}
+		if j.Reason != "" {
+			fmt.Fprintf(buf, "            Reason: %s\n", j.Reason)
+		}
+		if j.Attempts > 0 {
+			fmt.Fprintf(buf, "          Attempts: %d\n", j.Attempts)
+		}
 		fmt.Fprintf(buf, "          Reusable: %s\n", BoolToYesNo(j.IsReusable()))
 		fmt.Fprintf(buf, "            Script: %s\n", j.ShellTask.ShellScript)
 		fmt.Fprintf(buf, "             Input:")
@@ -133,19 +153,49 @@ func (v *WorkflowLog) Summary(failedOnly bool) string {
 		}
 		fmt.Fprint(buf, "\n")
 
+		if len(j.TouchedFiles) > 0 {
+			fmt.Fprintf(buf, "     Touched Files: %s\n", strings.Join(j.TouchedFiles, " "))
+		}
+
 		fmt.Fprintf(buf, " Dependent Job IDs:")
 		for _, x := range j.ShellTask.DependentTaskID {
 			fmt.Fprintf(buf, " %d", x)
 		}
 		fmt.Fprint(buf, "\n")
 
-		if j.SgeTaskID != "" {
-			fmt.Fprintf(buf, "       SGE Task ID: %s\n", strings.TrimSpace(j.SgeTaskID))
+		if j.JobID != "" {
+			fmt.Fprintf(buf, "           Backend: %s\n", j.Backend)
+			fmt.Fprintf(buf, "            Job ID: %s\n", j.JobID)
+		}
+
+		if j.ShellTask.CommandConfiguration.Container != "" {
+			fmt.Fprintf(buf, "         Container: %s\n", j.ShellTask.CommandConfiguration.Container)
+		}
+
+		if j.ShellTask.CommandConfiguration.Conda != "" {
+			fmt.Fprintf(buf, "             Conda: %s\n", j.ShellTask.CommandConfiguration.Conda)
+		}
+
+		if len(j.ShellTask.CommandConfiguration.Modules) > 0 {
+			fmt.Fprintf(buf, "           Modules: %s\n", strings.Join(j.ShellTask.CommandConfiguration.Modules, " "))
+		}
+
+		if j.ContainerImage != "" {
+			fmt.Fprintf(buf, "   Container Image: %s\n", j.ContainerImage)
+		}
+
+		if j.ResourceUsage != nil {
+			fmt.Fprintf(buf, "         Wall time: %s\n", formatSeconds(j.ResourceUsage.WallTime))
+			fmt.Fprintf(buf, "          CPU time: user %s / system %s\n", formatSeconds(j.ResourceUsage.UserTime), formatSeconds(j.ResourceUsage.SystemTime))
+			fmt.Fprintf(buf, "           Max RSS: %s\n", j.ResourceUsage.MaxRSS.String())
+			if j.ResourceUsage.MaxVMem.Byte() > 0 {
+				fmt.Fprintf(buf, "          Max VMem: %s\n", j.ResourceUsage.MaxVMem.String())
+			}
 		}
 
 		fmt.Fprintf(buf, "     Log directory: %s\n", j.JobLogRoot)
 
-		if j.State() == JobFailed {
+		if j.State() == JobFailed || j.State() == JobTimeout {
 			logfile, err := os.Open(path.Join(j.JobLogRoot, "script.stderr"))
 			if err == nil {
 				fmt.Fprintf(buf, "  - - - - - - Stderr - - - - - -\n")
@@ -169,10 +219,17 @@ type JobLog struct {
 	IsAnyInputChanged  bool
 	IsDone             bool
 	IsAnyOutputChanged bool
+	TouchedFiles       []string
 	ExitCode           int
 	ScriptExitCode     int
 	ShellTask          *ShellTask
-	SgeTaskID          string
+	Backend            string
+	JobID              string
+	ContainerImage     string
+	Fingerprint        *TaskFingerprint
+	Attempts           int
+	Reason             string
+	ResourceUsage      *ResourceUsage
 }
 
 func (v *JobLog) String() string {
@@ -195,6 +252,10 @@ func (v *JobLog) String() string {
 func (v *JobLog) State() JobState {
 	if v.IsDone && v.ExitCode == 0 {
 		return JobDone
+	} else if v.IsDone && v.ExitCode == TimeoutExitCode {
+		return JobTimeout
+	} else if v.IsDone && v.ExitCode == CancelledExitCode {
+		return JobCancelled
 	} else if v.IsDone {
 		return JobFailed
 	} else if v.IsStarted {
@@ -227,16 +288,10 @@ func CollectLogsForOneJob(jobRoot string, oneTask *ShellTask) (*JobLog, error) {
 	var err error
 	exitCodeFile, err = os.Open(path.Join(jobRoot, "rc"))
 	if os.IsNotExist(err) {
-		followUpDone, err := FollowUpLocalSingle(jobRoot)
+		followUpDone, err := FollowUpJob(jobRoot)
 		if err != nil {
 			return nil, fmt.Errorf("Failed to follow up %s : %s", jobRoot, err.Error())
 		}
-		if !followUpDone {
-			followUpDone, err = FollowUpSge(jobRoot)
-			if err != nil {
-				return nil, fmt.Errorf("Failed to follow up %s : %s", jobRoot, err.Error())
-			}
-		}
 		if !followUpDone {
 			exitCodeFile = bytes.NewReader([]byte("1000"))
 		} else {
@@ -266,19 +321,16 @@ func CollectLogsForOneJob(jobRoot string, oneTask *ShellTask) (*JobLog, error) {
 	var inputFiles []FileLog
 	var jobStarted bool
 	var anyInputChanged = false
+	var touchedFiles []string
 	err = LoadJsonFromFile(path.Join(jobRoot, "input.json"), &inputFiles)
 	if err == nil {
 		jobStarted = true
-		for _, v := range inputFiles {
-			//inputFiles[i].Content = nil
-			chg, err := v.IsChanged()
-			if err != nil {
-				return nil, err
-			}
-			if chg {
-				anyInputChanged = true
-			}
+		var touched []string
+		anyInputChanged, touched, err = checkFileLogs(inputFiles)
+		if err != nil {
+			return nil, err
 		}
+		touchedFiles = append(touchedFiles, touched...)
 	} else if os.IsNotExist(err) {
 		jobStarted = false
 	} else {
@@ -292,16 +344,12 @@ func CollectLogsForOneJob(jobRoot string, oneTask *ShellTask) (*JobLog, error) {
 	var outputFound = false
 	err = LoadJsonFromFile(path.Join(jobRoot, "output.json"), &outputFiles)
 	if err == nil {
-		for _, v := range outputFiles {
-			chg, err := v.IsChanged()
-			//outputFiles[i].Content = nil
-			if err != nil {
-				return nil, err
-			}
-			if chg {
-				anyOutputChanged = true
-			}
+		var touched []string
+		anyOutputChanged, touched, err = checkFileLogs(outputFiles)
+		if err != nil {
+			return nil, err
 		}
+		touchedFiles = append(touchedFiles, touched...)
 		outputFound = true
 	} else if os.IsNotExist(err) {
 		outputFound = false
@@ -314,19 +362,48 @@ func CollectLogsForOneJob(jobRoot string, oneTask *ShellTask) (*JobLog, error) {
 		exitCode = 1000
 	}
 
-	// check SGE task id
-	var sgeTaskID string
-	sgeTaskIDFile, err := os.Open(path.Join(jobRoot, "sge-taskid.txt"))
+	// check job ID given by a job scheduler
+	backend, jobID, err := readSchedulerJobID(jobRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	// check ID of container image
+	var containerImage string
+	containerImageData, err := ioutil.ReadFile(path.Join(jobRoot, containerImageFileName))
+	if err == nil {
+		containerImage = strings.TrimSpace(string(containerImageData))
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	// load fingerprint of a task
+	fingerprint, err := loadFingerprint(jobRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	// check reason of termination
+	var reason string
+	reasonData, err := ioutil.ReadFile(path.Join(jobRoot, jobReasonFileName))
 	if err == nil {
-		defer sgeTaskIDFile.Close()
-		data, err := ioutil.ReadAll(sgeTaskIDFile)
+		reason = strings.TrimSpace(string(reasonData))
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	// load resource usage of finished job
+	var resourceUsage *ResourceUsage
+	if jobDone {
+		resourceUsage, err = CollectResourceUsage(jobRoot)
 		if err != nil {
 			return nil, err
 		}
-		sgeTaskID = string(data)
-	} else if os.IsNotExist(err) {
-		// ignore
-	} else {
+	}
+
+	// count attempts of retried job
+	attempts, err := countAttempts(jobRoot)
+	if err != nil {
 		return nil, err
 	}
 
@@ -338,13 +415,76 @@ func CollectLogsForOneJob(jobRoot string, oneTask *ShellTask) (*JobLog, error) {
 		IsAnyInputChanged:  anyInputChanged,
 		IsDone:             jobDone,
 		IsAnyOutputChanged: anyOutputChanged,
+		TouchedFiles:       touchedFiles,
 		ExitCode:           exitCode,
 		ScriptExitCode:     scriptExitCode,
 		ShellTask:          oneTask,
-		SgeTaskID:          sgeTaskID,
+		Backend:            backend,
+		JobID:              jobID,
+		ContainerImage:     containerImage,
+		Fingerprint:        fingerprint,
+		Attempts:           attempts,
+		Reason:             reason,
+		ResourceUsage:      resourceUsage,
 	}, nil
 }
 
+// checkFileLogs checks whether any file is changed. Files whose contents
+// are not changed but modification date is changed are also returned.
+func checkFileLogs(files []FileLog) (bool, []string, error) {
+	var touched []string
+	for _, v := range files {
+		change, err := v.CheckChange()
+		if err != nil {
+			return true, nil, err
+		}
+		switch change {
+		case FileChanged:
+			return true, touched, nil
+		case FileTouched:
+			touched = append(touched, v.Relpath)
+		}
+	}
+	return false, touched, nil
+}
+
+// checkDependentFiles returns changed and touched input files of a workflow
+func checkDependentFiles(dependentFiles []FileLog) ([]string, []string, error) {
+	changedInput := make([]string, 0)
+	var touchedInput []string
+	for _, oneDependent := range dependentFiles {
+		change, err := oneDependent.CheckChange()
+		if err != nil {
+			return nil, nil, err
+		}
+		switch change {
+		case FileChanged:
+			changedInput = append(changedInput, oneDependent.Relpath)
+		case FileTouched:
+			touchedInput = append(touchedInput, oneDependent.Relpath)
+		}
+	}
+	return changedInput, touchedInput, nil
+}
+
+var attemptDirRegexp = regexp.MustCompile("^attempt\\d+$")
+
+func countAttempts(jobRoot string) (int, error) {
+	files, err := ioutil.ReadDir(jobRoot)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	attempts := 0
+	for _, v := range files {
+		if v.IsDir() && attemptDirRegexp.MatchString(v.Name()) {
+			attempts++
+		}
+	}
+	return attempts, nil
+}
+
 const workflowLogCacheFileName = "workflowLogCache.json.gz"
 
 func CollectLogsForOneWorkFromCache(logdirPath string) (*WorkflowLog, error) {
@@ -371,51 +511,40 @@ func CollectLogsForOneWorkFromCache(logdirPath string) (*WorkflowLog, error) {
 		return nil, err
 	}
 
-	changedInput := make([]string, 0)
-	for _, oneDependent := range dependentFiles {
-		changed, err := oneDependent.IsChanged()
-		if err != nil {
-			return nil, err
-		}
-		if changed {
-			changedInput = append(changedInput, oneDependent.Relpath)
-		}
+	workflowLog.ChangedInput, workflowLog.TouchedInput, err = checkDependentFiles(dependentFiles)
+	if err != nil {
+		return nil, err
 	}
-	workflowLog.ChangedInput = changedInput
 
 	// re-check files
 	for i, job := range workflowLog.JobLogs {
 		if job.IsDone {
-			if !job.IsAnyInputChanged {
-				anyInputChanged := false
-				for _, v := range job.InputFiles {
-					changed, err := v.IsChanged()
-					if err != nil {
-						return nil, err
-					}
-
-					if changed {
-						anyInputChanged = true
-						break
-					}
+			// changed files may be touched only if SHA256 is compared
+			var touchedFiles []string
+			if !job.IsAnyInputChanged || CompareContentHash {
+				anyInputChanged, touched, err := checkFileLogs(job.InputFiles)
+				if err != nil {
+					return nil, err
 				}
 				job.IsAnyInputChanged = anyInputChanged
+				touchedFiles = append(touchedFiles, touched...)
 			}
 
-			if !job.IsAnyOutputChanged {
-				anyOutputChanged := false
-				for _, v := range job.OutputFiles {
-					changed, err := v.IsChanged()
-					if err != nil {
-						return nil, err
-					}
-
-					if changed {
-						anyOutputChanged = true
-						break
-					}
+			if !job.IsAnyOutputChanged || CompareContentHash {
+				anyOutputChanged, touched, err := checkFileLogs(job.OutputFiles)
+				if err != nil {
+					return nil, err
 				}
 				job.IsAnyOutputChanged = anyOutputChanged
+				touchedFiles = append(touchedFiles, touched...)
+			}
+			job.TouchedFiles = touchedFiles
+
+			if job.ResourceUsage == nil {
+				job.ResourceUsage, err = CollectResourceUsage(job.JobLogRoot)
+				if err != nil {
+					return nil, err
+				}
 			}
 
 		} else {
@@ -468,15 +597,9 @@ func CollectLogsForOneWorkWithScan(logdirPath string) (*WorkflowLog, error) {
 		return nil, err
 	}
 
-	changedInput := make([]string, 0)
-	for _, oneDependent := range dependentFiles {
-		changed, err := oneDependent.IsChanged()
-		if err != nil {
-			return nil, err
-		}
-		if changed {
-			changedInput = append(changedInput, oneDependent.Relpath)
-		}
+	changedInput, touchedInput, err := checkDependentFiles(dependentFiles)
+	if err != nil {
+		return nil, err
 	}
 
 	for _, oneTask := range metadata.Tasks {
@@ -496,6 +619,7 @@ func CollectLogsForOneWorkWithScan(logdirPath string) (*WorkflowLog, error) {
 		StartDate:       metadata.Date,
 		JobLogs:         jobs,
 		ChangedInput:    changedInput,
+		TouchedInput:    touchedInput,
 	}
 
 	cacheFile, err := os.OpenFile(path.Join(logdirPath, workflowLogCacheFileName), os.O_CREATE|os.O_WRONLY, 0644)
@@ -558,19 +682,118 @@ func (v WorkflowLogArray) Len() int           { return len(v) }
 func (v WorkflowLogArray) Swap(i, j int)      { v[i], v[j] = v[j], v[i] }
 func (v WorkflowLogArray) Less(i, j int) bool { return v[i].StartDate.Before(v[j].StartDate) }
 
-func (v WorkflowLogArray) SearchReusableJob(shellscript string, workdir string, dependentFiles flowscript.StringSet, creatingFiles flowscript.StringSet) *JobLog {
+// SearchReusableJob searches a job which can be reused for a command. If no
+// job is found, reasons why the latest job of the same command cannot be
+// reused are returned.
+func (v WorkflowLogArray) SearchReusableJob(shellscript string, workdir string, dependentFiles flowscript.StringSet, creatingFiles flowscript.StringSet, conf *CommandConfiguration, fingerprint *TaskFingerprint) (*JobLog, []string) {
+	// ID of container image is checked only when a job is found
+	var imageID *string
+	var reasons []string
+	var similarReasons []string
 
 	for _, x := range v {
 		for _, y := range x.JobLogs {
-			if (!y.IsReusable()) || (y.ShellTask.ShellScript != shellscript) || (!reflect.DeepEqual(y.ShellTask.DependentFiles, dependentFiles)) {
+			if (y.ShellTask.ShellScript != shellscript) || (!reflect.DeepEqual(y.ShellTask.DependentFiles, dependentFiles)) {
+				// a job which created same files is compared if no job of the same command is found
+				if creatingFiles.Size() > 0 && reflect.DeepEqual(y.ShellTask.CreatingFiles, creatingFiles) {
+					similarReasons = make([]string, 0)
+					if y.ShellTask.ShellScript != shellscript {
+						similarReasons = append(similarReasons, "command script differs from previous job")
+					}
+					if !reflect.DeepEqual(y.ShellTask.DependentFiles, dependentFiles) {
+						similarReasons = append(similarReasons, "input files differ from previous job")
+					}
+				}
 				continue
 			}
-			//fmt.Printf("found %s\n", y.JobLogRoot)
-			return y
+
+			var problems []string
+			if !y.IsReusable() {
+				problems = append(problems, y.notReusableReasons()...)
+			}
+			if y.ShellTask.CommandConfiguration.Container != conf.Container || y.ShellTask.CommandConfiguration.ContainerEngineName() != conf.ContainerEngineName() || y.ShellTask.CommandConfiguration.Singularity != conf.Singularity {
+				problems = append(problems, "container is changed")
+			} else if conf.Container != "" {
+				if imageID == nil {
+					id, err := containerImageID(conf.ContainerEngineName(), conf.Container)
+					if err != nil {
+						// image is not available yet
+						id = ""
+					}
+					imageID = &id
+				}
+				if *imageID == "" || y.ContainerImage != *imageID {
+					problems = append(problems, "container image is changed")
+				}
+			}
+			// jobs logged before fingerprint was introduced are not checked
+			if fingerprint != nil && y.Fingerprint != nil {
+				problems = append(problems, fingerprint.Differences(y.Fingerprint)...)
+			}
+
+			if len(problems) == 0 {
+				//fmt.Printf("found %s\n", y.JobLogRoot)
+				return y, nil
+			}
+			// logs are sorted by start date
+			reasons = problems
 		}
 	}
 
-	return nil
+	if reasons == nil {
+		reasons = similarReasons
+	}
+	if reasons == nil {
+		reasons = []string{"no previous log of the command"}
+	}
+	return nil, reasons
+}
+
+// notReusableReasons explains why a job cannot be reused
+func (v *JobLog) notReusableReasons() []string {
+	reasons := make([]string, 0)
+	switch v.State() {
+	case JobDone:
+	case JobFailed:
+		if v.ExitCode == 1000 {
+			reasons = append(reasons, "previous job vanished (exit code 1000)")
+		} else {
+			reasons = append(reasons, fmt.Sprintf("previous job failed with exit code %d", v.ExitCode))
+		}
+	case JobTimeout:
+		reasons = append(reasons, "previous job timed out")
+	case JobCancelled:
+		reasons = append(reasons, "previous job was cancelled")
+	default:
+		reasons = append(reasons, "previous job is not finished")
+	}
+
+	found := false
+	for _, x := range []struct {
+		name  string
+		files []FileLog
+	}{
+		{"input", v.InputFiles},
+		{"output", v.OutputFiles},
+	} {
+		for _, f := range x.files {
+			change, description, err := f.ExplainChange()
+			if err != nil {
+				reasons = append(reasons, fmt.Sprintf("cannot check %s %s: %s", x.name, f.Relpath, err.Error()))
+				found = true
+			} else if change == FileChanged && x.name == "output" && description == "removed" {
+				reasons = append(reasons, fmt.Sprintf("output %s is missing", f.Relpath))
+				found = true
+			} else if change == FileChanged {
+				reasons = append(reasons, fmt.Sprintf("%s %s is changed (%s)", x.name, f.Relpath, description))
+				found = true
+			}
+		}
+	}
+	if !found && (v.IsAnyInputChanged || v.IsAnyOutputChanged) {
+		reasons = append(reasons, "input or output files are changed")
+	}
+	return reasons
 }
 
 const viewLogShowMax = 10
@@ -581,7 +804,7 @@ func ViewLog(showAll bool, failedOnly bool) error {
 		return err
 	}
 
-	fmt.Printf("%3s|%7s|Success|Failed|Running|Pending|File Changed|%-19s|Name\n", "#", "State", "Start Date")
+	fmt.Printf("%3s|%9s|Success|Failed|Running|Pending|File Changed|%-19s|Name\n", "#", "State", "Start Date")
 
 	showLogs := make([]int, 0)
 
@@ -615,7 +838,7 @@ func ViewLog(showAll bool, failedOnly bool) error {
 			switch x.State() {
 			case JobDone:
 				successJobs++
-			case JobFailed:
+			case JobFailed, JobTimeout, JobCancelled:
 				failedJobs++
 			case JobRunning:
 				runningJobs++
@@ -629,7 +852,7 @@ func ViewLog(showAll bool, failedOnly bool) error {
 			name += " " + path.Base(v.ParameterFile)
 		}
 
-		fmt.Printf("%3d|%7s|%7d|%6d|%7d|%7d|%12s|%10s|%s\n", i+1, v.State().String()[8:], successJobs, failedJobs, runningJobs, notStartedJobs, files, v.StartDate.Format("2006/01/02 15:04:05"), name)
+		fmt.Printf("%3d|%9s|%7d|%6d|%7d|%7d|%12s|%10s|%s\n", i+1, v.State().String()[8:], successJobs, failedJobs, runningJobs, notStartedJobs, files, v.StartDate.Format("2006/01/02 15:04:05"), name)
 	}
 	return nil
 }
@@ -657,3 +880,45 @@ func ViewLogDetail(args []string, failedOnly bool) error {
 	}
 	return nil
 }
+
+// ResourceUsageSummary returns a table of resource usage of jobs
+func (v *WorkflowLog) ResourceUsageSummary() string {
+	var buf = bytes.NewBuffer(nil)
+	var totalWallTime, totalCPUTime float64
+	var maxRSS Memory
+
+	fmt.Fprintf(buf, "%4s|%12s|%12s|%12s|%10s|%-12s|Script\n", "ID", "Wall time", "User time", "System time", "Max RSS", "State")
+	for _, j := range v.JobLogs {
+		script := strings.Replace(j.ShellTask.ShellScript, "\n", " ", -1)
+		if j.ResourceUsage == nil {
+			fmt.Fprintf(buf, "%4d|%12s|%12s|%12s|%10s|%-12s|%s\n", j.ShellTask.ID, "-", "-", "-", "-", j.State().String(), script)
+			continue
+		}
+		u := j.ResourceUsage
+		fmt.Fprintf(buf, "%4d|%12s|%12s|%12s|%10s|%-12s|%s\n", j.ShellTask.ID, formatSeconds(u.WallTime), formatSeconds(u.UserTime), formatSeconds(u.SystemTime), u.MaxRSS.String(), j.State().String(), script)
+		totalWallTime += u.WallTime
+		totalCPUTime += u.UserTime + u.SystemTime
+		if u.MaxRSS.Byte() > maxRSS.Byte() {
+			maxRSS = u.MaxRSS
+		}
+	}
+	fmt.Fprintf(buf, "Total wall time: %s / Total CPU time: %s / Max RSS: %s\n", formatSeconds(totalWallTime), formatSeconds(totalCPUTime), maxRSS.String())
+	return string(buf.Bytes())
+}
+
+func ViewResourceUsage(args []string) error {
+	logs, err := CollectLogs(WorkflowLogDir)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range args {
+		val, err := strconv.ParseInt(v, 10, 32)
+		if err == nil && val > 0 && val <= int64(len(logs)) {
+			fmt.Printf("%s", logs[val-1].ResourceUsageSummary())
+		} else {
+			fmt.Fprintf(os.Stderr, "Bad Workflow Record Number: %s\n", v)
+		}
+	}
+	return nil
+}
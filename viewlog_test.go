@@ -64,6 +64,7 @@ func TestCollectLog(t *testing.T) {
 				ExitCode:           -1,
 				ScriptExitCode:     -1,
 				ShellTask:          builder.Tasks[0],
+				Fingerprint:        builder.Fingerprint(1),
 			},
 			&JobLog{
 				JobLogRoot:         path.Join(workflowLogRoot, "job002"),
@@ -74,6 +75,7 @@ func TestCollectLog(t *testing.T) {
 				ExitCode:           -1,
 				ScriptExitCode:     -1,
 				ShellTask:          builder.Tasks[1],
+				Fingerprint:        builder.Fingerprint(2),
 			},
 			&JobLog{
 				JobLogRoot:         path.Join(workflowLogRoot, "job003"),
@@ -84,6 +86,7 @@ func TestCollectLog(t *testing.T) {
 				ExitCode:           -1,
 				ScriptExitCode:     -1,
 				ShellTask:          builder.Tasks[2],
+				Fingerprint:        builder.Fingerprint(3),
 			},
 		},
 	}
@@ -165,6 +168,7 @@ func TestCollectLog(t *testing.T) {
 				ExitCode:           0,
 				ScriptExitCode:     0,
 				ShellTask:          builder.Tasks[0],
+				Fingerprint:        builder.Fingerprint(1),
 			},
 			&JobLog{
 				JobLogRoot:         path.Join(workflowLogRoot, "job002"),
@@ -175,6 +179,7 @@ func TestCollectLog(t *testing.T) {
 				ExitCode:           -1,
 				ScriptExitCode:     -1,
 				ShellTask:          builder.Tasks[1],
+				Fingerprint:        builder.Fingerprint(2),
 			},
 			&JobLog{
 				JobLogRoot:         path.Join(workflowLogRoot, "job003"),
@@ -185,6 +190,7 @@ func TestCollectLog(t *testing.T) {
 				ExitCode:           -1,
 				ScriptExitCode:     -1,
 				ShellTask:          builder.Tasks[2],
+				Fingerprint:        builder.Fingerprint(3),
 			},
 		},
 	}
@@ -259,6 +265,7 @@ func TestCollectLog(t *testing.T) {
 				ExitCode:           0,
 				ScriptExitCode:     0,
 				ShellTask:          builder.Tasks[0],
+				Fingerprint:        builder.Fingerprint(1),
 			},
 			&JobLog{
 				JobLogRoot:         path.Join(workflowLogRoot, "job002"),
@@ -269,6 +276,7 @@ func TestCollectLog(t *testing.T) {
 				ExitCode:           0,
 				ScriptExitCode:     0,
 				ShellTask:          builder.Tasks[1],
+				Fingerprint:        builder.Fingerprint(2),
 			},
 			&JobLog{
 				JobLogRoot:         path.Join(workflowLogRoot, "job003"),
@@ -279,6 +287,7 @@ func TestCollectLog(t *testing.T) {
 				ExitCode:           0,
 				ScriptExitCode:     0,
 				ShellTask:          builder.Tasks[2],
+				Fingerprint:        builder.Fingerprint(3),
 			},
 		},
 	}
@@ -309,6 +318,11 @@ func TestCollectLog(t *testing.T) {
 		expectedLogs.JobLogs[i].OutputFiles = v.OutputFiles
 		expectedLogs.JobLogs[i].InputFiles = v.InputFiles
 
+		if v.ResourceUsage == nil && !v.ShellTask.ShouldSkip {
+			t.Fatalf("resource usage should be recorded: %d : %s", i, v)
+		}
+		expectedLogs.JobLogs[i].ResourceUsage = v.ResourceUsage
+
 		if !reflect.DeepEqual(v, expectedLogs.JobLogs[i]) {
 			u := expectedLogs.JobLogs[i]
 
@@ -359,6 +373,7 @@ func TestCollectLog(t *testing.T) {
 				ExitCode:           0,
 				ScriptExitCode:     0,
 				ShellTask:          builder.Tasks[0],
+				Fingerprint:        builder.Fingerprint(1),
 			},
 			&JobLog{
 				JobLogRoot:         path.Join(workflowLogRoot, "job002"),
@@ -369,6 +384,7 @@ func TestCollectLog(t *testing.T) {
 				ExitCode:           0,
 				ScriptExitCode:     0,
 				ShellTask:          builder.Tasks[1],
+				Fingerprint:        builder.Fingerprint(2),
 			},
 			&JobLog{
 				JobLogRoot:         path.Join(workflowLogRoot, "job003"),
@@ -379,6 +395,7 @@ func TestCollectLog(t *testing.T) {
 				ExitCode:           0,
 				ScriptExitCode:     0,
 				ShellTask:          builder.Tasks[2],
+				Fingerprint:        builder.Fingerprint(3),
 			},
 		},
 	}
@@ -402,6 +419,11 @@ func TestCollectLog(t *testing.T) {
 
 		expectedLogs.JobLogs[i].OutputFiles = v.OutputFiles
 		expectedLogs.JobLogs[i].InputFiles = v.InputFiles
+
+		if v.ResourceUsage == nil && !v.ShellTask.ShouldSkip {
+			t.Fatalf("resource usage should be recorded: %d : %s", i, v)
+		}
+		expectedLogs.JobLogs[i].ResourceUsage = v.ResourceUsage
 	}
 
 	for j, y := range log.JobLogs {
@@ -4,9 +4,9 @@ package main
 
 import "strconv"
 
-const _WorkflowState_name = "WorkflowDoneWorkflowRunningWorkflowFailedWorkflowUnknown"
+const _WorkflowState_name = "WorkflowDoneWorkflowRunningWorkflowFailedWorkflowUnknownWorkflowCancelled"
 
-var _WorkflowState_index = [...]uint8{0, 12, 27, 41, 56}
+var _WorkflowState_index = [...]uint8{0, 12, 27, 41, 56, 73}
 
 func (i WorkflowState) String() string {
 	if i < 0 || i >= WorkflowState(len(_WorkflowState_index)-1) {